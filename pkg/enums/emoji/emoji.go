@@ -5,4 +5,7 @@ package emoji
 
 const (
 	CheckMark string = "\xE2\x9C\x85"
+	CrossMark string = "\xE2\x9D\x8C"
+	Hourglass string = "\xE2\x8F\xB3"
+	Warning   string = "\xE2\x9A\xA0\xEF\xB8\x8F"
 )
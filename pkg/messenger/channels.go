@@ -0,0 +1,321 @@
+package messenger
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ardihikaru/go-modules/pkg/utils/web"
+)
+
+// defaultTelegramEndpoint defines the telegram bot API server
+const defaultTelegramEndpoint = "https://api.telegram.org"
+
+// defaultChannelTimeout defines the HTTP timeout of the channels without a configured client
+const defaultChannelTimeout = 30 * time.Second
+
+// WhatsappChannel sends the notifications to the whatsapp phone of the recipient
+type WhatsappChannel struct {
+	Sender Sender
+}
+
+// Name returns the channel name
+func (c *WhatsappChannel) Name() string {
+	return ChannelWhatsapp
+}
+
+// Send sends the notification, the subject (if any) is prepended to the message
+func (c *WhatsappChannel) Send(ctx context.Context, n Notification) error {
+	if n.Recipient.Phone == "" {
+		return ErrNoAddress
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	msg := n.Message
+	if n.Subject != "" {
+		msg = fmt.Sprintf("%s\n\n%s", n.Subject, n.Message)
+	}
+
+	sent, respMsg, err := SendMsgToWhatsapp(ctx, c.Sender, n.Recipient.Phone, msg)
+	if err != nil {
+		return err
+	}
+	if !sent {
+		return fmt.Errorf("whatsapp message is not sent: %s", respMsg)
+	}
+
+	return nil
+}
+
+// TelegramChannel sends the notifications to the telegram chat of the recipient via the bot API
+type TelegramChannel struct {
+	Token string
+
+	// Endpoint is the bot API server, empty uses the telegram server (e.g. set a local stand-in for tests)
+	Endpoint string
+
+	HttpClient *http.Client
+}
+
+// Name returns the channel name
+func (c *TelegramChannel) Name() string {
+	return ChannelTelegram
+}
+
+// Send sends the notification, the subject (if any) is prepended to the message
+func (c *TelegramChannel) Send(ctx context.Context, n Notification) error {
+	if n.Recipient.TelegramChatId == 0 {
+		return ErrNoAddress
+	}
+
+	text := n.Message
+	if n.Subject != "" {
+		text = fmt.Sprintf("%s\n\n%s", n.Subject, n.Message)
+	}
+
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = defaultTelegramEndpoint
+	}
+	apiUrl := fmt.Sprintf("%s/bot%s/sendMessage", strings.TrimSuffix(endpoint, "/"), c.Token)
+
+	body, err := postJSON(ctx, channelClient(c.HttpClient), apiUrl, nil, map[string]interface{}{
+		"chat_id": n.Recipient.TelegramChatId,
+		"text":    text,
+	})
+	err = redactToken(err, c.Token)
+	if err != nil && body == nil {
+		return err
+	}
+
+	// telegram describes the failure in the response body, even on a non-2xx response
+	var resp struct {
+		Ok          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if jsonErr := json.Unmarshal(body, &resp); jsonErr != nil {
+		if err != nil {
+			return err
+		}
+		return jsonErr
+	}
+	if !resp.Ok {
+		return fmt.Errorf("telegram message is not sent: %s", resp.Description)
+	}
+
+	return nil
+}
+
+// redactToken hides the bot token from the request URL of the error, since the results are serialised
+func redactToken(err error, token string) error {
+	var urlErr *url.Error
+	if err == nil || token == "" || !errors.As(err, &urlErr) {
+		return err
+	}
+
+	redacted := &url.Error{Op: urlErr.Op, URL: strings.ReplaceAll(urlErr.URL, token, "<token>"), Err: urlErr.Err}
+	if strings.Contains(redacted.Error(), token) {
+		return fmt.Errorf("telegram request failed: %s", strings.ReplaceAll(redacted.Error(), token, "<token>"))
+	}
+
+	return redacted
+}
+
+// EmailChannel sends the notifications to the email of the recipient via SMTP
+type EmailChannel struct {
+	// Addr is the SMTP server address, e.g. "smtp.example.com:587"
+	Addr string
+
+	// Auth authenticates to the SMTP server if exists, e.g. smtp.PlainAuth
+	Auth smtp.Auth
+
+	From string
+
+	// TLSConfig is used on STARTTLS if the server supports it, nil uses the server name of Addr
+	TLSConfig *tls.Config
+
+	// Timeout bounds the whole SMTP session, zero uses the default timeout
+	Timeout time.Duration
+}
+
+// Name returns the channel name
+func (c *EmailChannel) Name() string {
+	return ChannelEmail
+}
+
+// Send sends the notification as a plain text email
+func (c *EmailChannel) Send(ctx context.Context, n Notification) error {
+	if n.Recipient.Email == "" {
+		return ErrNoAddress
+	}
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = defaultChannelTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", c.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	host, _, err := net.SplitHostPort(c.Addr)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		tlsConfig := c.TLSConfig
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{ServerName: host}
+		}
+		err = client.StartTLS(tlsConfig)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.Auth != nil {
+		err = client.Auth(c.Auth)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = client.Mail(c.From)
+	if err != nil {
+		return err
+	}
+	err = client.Rcpt(n.Recipient.Email)
+	if err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buildEmail(c.From, n.Recipient.Email, n.Subject, n.Message))
+	if err != nil {
+		_ = w.Close()
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// buildEmail builds the plain text email message
+func buildEmail(from, to, subject, body string) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString(fmt.Sprintf("From: %s\r\n", from))
+	buf.WriteString(fmt.Sprintf("To: %s\r\n", to))
+	buf.WriteString(fmt.Sprintf("Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject)))
+	buf.WriteString(fmt.Sprintf("Date: %s\r\n", time.Now().Format(time.RFC1123Z)))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	return buf.Bytes()
+}
+
+// WebhookChannel posts the notifications as JSON to a generic HTTP webhook
+type WebhookChannel struct {
+	Url string
+
+	// Headers are added to every request, e.g. the authorization header
+	Headers map[string]string
+
+	HttpClient *http.Client
+}
+
+// Name returns the channel name
+func (c *WebhookChannel) Name() string {
+	return ChannelWebhook
+}
+
+// Send posts the notification, any 2xx response is considered as delivered
+func (c *WebhookChannel) Send(ctx context.Context, n Notification) error {
+	if c.Url == "" {
+		return ErrNoAddress
+	}
+
+	_, err := postJSON(ctx, channelClient(c.HttpClient), c.Url, c.Headers, n)
+
+	return err
+}
+
+// channelClient returns the configured client, or a client with the default timeout
+func channelClient(client *http.Client) *http.Client {
+	if client != nil {
+		return client
+	}
+
+	return &http.Client{Timeout: defaultChannelTimeout}
+}
+
+// postJSON posts the JSON payload, and returns the response body of a 2xx response
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string,
+	payload interface{}) ([]byte, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payloadBytes))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(web.HeaderContentTypeKey, web.HeaderContentTypeValue)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return body, fmt.Errorf("got unexpected status code [%d]", resp.StatusCode)
+	}
+
+	return body, nil
+}
@@ -0,0 +1,202 @@
+package messenger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// notification channels
+const (
+	ChannelWhatsapp = "whatsapp"
+	ChannelTelegram = "telegram"
+	ChannelEmail    = "email"
+	ChannelWebhook  = "webhook"
+)
+
+var (
+	ErrNoAddress      = errors.New("recipient has no address for this channel")
+	ErrUnknownChannel = errors.New("channel is not registered")
+	ErrNoChannel      = errors.New("no channel to send the notification")
+	ErrNotDelivered   = errors.New("notification is not delivered")
+)
+
+// Recipient defines the addresses of the notification recipient
+type Recipient struct {
+	// Id identifies the recipient, e.g. to look up the channel preferences
+	Id string `json:"id,omitempty"`
+
+	Phone          string `json:"phone,omitempty"`
+	TelegramChatId int64  `json:"telegram_chat_id,omitempty"`
+	Email          string `json:"email,omitempty"`
+
+	// Channels lists the preferred channels in order, it has higher priority than the preference store
+	Channels []string `json:"channels,omitempty"`
+}
+
+// Notification defines the notification to dispatch
+type Notification struct {
+	Recipient Recipient              `json:"recipient"`
+	Subject   string                 `json:"subject,omitempty"`
+	Message   string                 `json:"message"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+
+	// Broadcast sends to every preferred channel, otherwise the next channel is only used as a fallback
+	Broadcast bool `json:"-"`
+}
+
+// Channel defines a notification channel driver
+type Channel interface {
+	// Name returns the channel name, e.g. ChannelEmail
+	Name() string
+
+	// Send sends the notification, it returns ErrNoAddress if the recipient has no address for this channel
+	Send(ctx context.Context, n Notification) error
+}
+
+// PreferenceStore defines the storage of the channel preferences of each recipient
+type PreferenceStore interface {
+	// Channels gets the preferred channels of the designated recipient in order, empty uses the default channels
+	Channels(ctx context.Context, recipientId string) ([]string, error)
+}
+
+// StaticPreferences stores the channel preferences in memory, mapped by the recipient ID
+type StaticPreferences map[string][]string
+
+// Channels gets the preferred channels of the designated recipient
+func (p StaticPreferences) Channels(_ context.Context, recipientId string) ([]string, error) {
+	return p[recipientId], nil
+}
+
+// ChannelResult defines the result of a channel
+type ChannelResult struct {
+	Channel  string        `json:"channel"`
+	Sent     bool          `json:"sent"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+
+	Err error `json:"-"`
+}
+
+// DispatchResult defines the result of the dispatched notification, with the result of each attempted channel
+type DispatchResult struct {
+	Delivered bool            `json:"delivered"`
+	Results   []ChannelResult `json:"results"`
+}
+
+// Dispatcher sends the notifications through the registered channels
+type Dispatcher struct {
+	// DefaultChannels lists the channels in order, used if the recipient has no preference
+	DefaultChannels []string
+
+	// Preferences stores the channel preferences of the recipients if exists
+	Preferences PreferenceStore
+
+	mu       sync.RWMutex
+	channels map[string]Channel
+}
+
+// NewDispatcher builds the dispatcher with the registered channels
+func NewDispatcher(defaultChannels []string, channels ...Channel) *Dispatcher {
+	d := &Dispatcher{
+		DefaultChannels: defaultChannels,
+		channels:        make(map[string]Channel),
+	}
+	for _, ch := range channels {
+		d.Register(ch)
+	}
+
+	return d
+}
+
+// Register registers the channel, an existing channel with the same name is replaced
+func (d *Dispatcher) Register(ch Channel) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.channels[ch.Name()] = ch
+}
+
+// Send sends the notification through the preferred channels of the recipient
+// by default, the next channel is only tried if the previous one failed (fallback)
+// the result is always returned, and the error is ErrNotDelivered (joined with the channel errors) on failure
+func (d *Dispatcher) Send(ctx context.Context, n Notification) (*DispatchResult, error) {
+	channels, err := d.channelsOf(ctx, n.Recipient)
+	if err != nil {
+		return &DispatchResult{}, err
+	}
+	if len(channels) == 0 {
+		return &DispatchResult{}, ErrNoChannel
+	}
+
+	result := &DispatchResult{}
+	errs := []error{ErrNotDelivered}
+	for _, name := range channels {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+
+		chResult := d.sendTo(ctx, name, n)
+		result.Results = append(result.Results, chResult)
+		if chResult.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, chResult.Err))
+			continue
+		}
+
+		result.Delivered = true
+		if !n.Broadcast {
+			break
+		}
+	}
+
+	if !result.Delivered {
+		return result, errors.Join(errs...)
+	}
+
+	return result, nil
+}
+
+// sendTo sends the notification through the designated channel
+func (d *Dispatcher) sendTo(ctx context.Context, name string, n Notification) ChannelResult {
+	d.mu.RLock()
+	ch, ok := d.channels[name]
+	d.mu.RUnlock()
+
+	result := ChannelResult{Channel: name}
+	if !ok {
+		result.Err = ErrUnknownChannel
+	} else {
+		start := time.Now()
+		result.Err = ch.Send(ctx, n)
+		result.Duration = time.Since(start)
+	}
+
+	result.Sent = result.Err == nil
+	if result.Err != nil {
+		result.Error = result.Err.Error()
+	}
+
+	return result
+}
+
+// channelsOf resolves the preferred channels of the recipient
+func (d *Dispatcher) channelsOf(ctx context.Context, r Recipient) ([]string, error) {
+	if len(r.Channels) > 0 {
+		return r.Channels, nil
+	}
+
+	if d.Preferences != nil && r.Id != "" {
+		channels, err := d.Preferences.Channels(ctx, r.Id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the channel preferences of [%s]: %w", r.Id, err)
+		}
+		if len(channels) > 0 {
+			return channels, nil
+		}
+	}
+
+	return d.DefaultChannels, nil
+}
@@ -0,0 +1,388 @@
+package messenger
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testBotToken = "123456:secret-token"
+
+// telegramStandIn stands in for the telegram bot API, it fails the sending if fail is set
+type telegramStandIn struct {
+	*httptest.Server
+
+	mu   sync.Mutex
+	fail bool
+	sent []map[string]interface{}
+}
+
+func newTelegramStandIn(t *testing.T) *telegramStandIn {
+	s := &telegramStandIn{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != fmt.Sprintf("/bot%s/sendMessage", testBotToken) {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, `{"ok":false,"description":"Not Found"}`)
+			return
+		}
+
+		var payload map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.fail {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprint(w, `{"ok":false,"description":"Bad Request: chat not found"}`)
+			return
+		}
+		s.sent = append(s.sent, payload)
+		_, _ = fmt.Fprint(w, `{"ok":true,"result":{"message_id":1}}`)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *telegramStandIn) sentCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.sent)
+}
+
+// webhookStandIn stands in for the generic webhook, and records the posted notifications
+type webhookStandIn struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	status  int
+	posted  []Notification
+	headers []http.Header
+}
+
+func newWebhookStandIn(t *testing.T, status int) *webhookStandIn {
+	s := &webhookStandIn{status: status}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n Notification
+		_ = json.NewDecoder(r.Body).Decode(&n)
+
+		s.mu.Lock()
+		s.posted = append(s.posted, n)
+		s.headers = append(s.headers, r.Header.Clone())
+		s.mu.Unlock()
+
+		w.WriteHeader(s.status)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// smtpStandIn is a minimal SMTP server, which accepts every message without STARTTLS nor authentication
+type smtpStandIn struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	rcptCode string
+	messages []string
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &smtpStandIn{ln: ln, rcptCode: "250"}
+	go s.serve()
+	t.Cleanup(func() { _ = ln.Close() })
+
+	return s
+}
+
+func (s *smtpStandIn) addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *smtpStandIn) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpStandIn) handle(conn net.Conn) {
+	defer conn.Close()
+
+	rd := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = fmt.Fprintf(conn, "%s\r\n", line) }
+
+	reply("220 localhost ESMTP stand-in")
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250-localhost")
+			reply("250 8BITMIME")
+		case strings.HasPrefix(cmd, "MAIL FROM"):
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO"):
+			s.mu.Lock()
+			code := s.rcptCode
+			s.mu.Unlock()
+			reply(code + " recipient")
+		case cmd == "DATA":
+			reply("354 end with <CRLF>.<CRLF>")
+
+			var msg strings.Builder
+			for {
+				dataLine, err := rd.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				msg.WriteString(dataLine)
+			}
+
+			s.mu.Lock()
+			s.messages = append(s.messages, msg.String())
+			s.mu.Unlock()
+			reply("250 OK queued")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (s *smtpStandIn) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.messages...)
+}
+
+// testDispatcher builds the dispatcher with the telegram, email and webhook channels of the stand-ins
+func testDispatcher(t *testing.T) (*Dispatcher, *telegramStandIn, *smtpStandIn, *webhookStandIn) {
+	tg := newTelegramStandIn(t)
+	mail := newSMTPStandIn(t)
+	hook := newWebhookStandIn(t, http.StatusNoContent)
+
+	d := NewDispatcher([]string{ChannelTelegram, ChannelEmail},
+		&TelegramChannel{Token: testBotToken, Endpoint: tg.URL},
+		&EmailChannel{Addr: mail.addr(), From: "bot@example.com"},
+		&WebhookChannel{Url: hook.URL, Headers: map[string]string{"Authorization": "Bearer test"}},
+	)
+
+	return d, tg, mail, hook
+}
+
+func TestDispatcherFallback(t *testing.T) {
+	d, tg, mail, _ := testDispatcher(t)
+	tg.fail = true
+
+	n := Notification{
+		Recipient: Recipient{TelegramChatId: 42, Email: "agent@example.com"},
+		Subject:   "Ticket #1",
+		Message:   "a new conversation is waiting",
+	}
+	result, err := d.Send(context.Background(), n)
+	if err != nil {
+		t.Fatalf("expected the email fallback to deliver, got %v", err)
+	}
+
+	if !result.Delivered || len(result.Results) != 2 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if r := result.Results[0]; r.Channel != ChannelTelegram || r.Sent || !strings.Contains(r.Error, "chat not found") {
+		t.Fatalf("unexpected telegram result %+v", r)
+	}
+	if r := result.Results[1]; r.Channel != ChannelEmail || !r.Sent || r.Error != "" {
+		t.Fatalf("unexpected email result %+v", r)
+	}
+
+	messages := mail.received()
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 email, got %d", len(messages))
+	}
+	if !strings.Contains(messages[0], "To: agent@example.com") ||
+		!strings.Contains(messages[0], "a new conversation is waiting") {
+		t.Fatalf("unexpected email %q", messages[0])
+	}
+}
+
+func TestDispatcherStopsOnFirstDelivery(t *testing.T) {
+	d, tg, mail, _ := testDispatcher(t)
+
+	result, err := d.Send(context.Background(), Notification{
+		Recipient: Recipient{TelegramChatId: 42, Email: "agent@example.com"},
+		Message:   "hello",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Results) != 1 || result.Results[0].Channel != ChannelTelegram {
+		t.Fatalf("expected only the telegram channel, got %+v", result.Results)
+	}
+	if tg.sentCount() != 1 || len(mail.received()) != 0 {
+		t.Fatal("the fallback must not be used once delivered")
+	}
+	if text := tg.sent[0]["text"]; text != "hello" {
+		t.Fatalf("unexpected telegram text %v", text)
+	}
+}
+
+func TestDispatcherBroadcast(t *testing.T) {
+	d, tg, mail, hook := testDispatcher(t)
+
+	result, err := d.Send(context.Background(), Notification{
+		Recipient: Recipient{
+			TelegramChatId: 42,
+			Email:          "agent@example.com",
+			Channels:       []string{ChannelTelegram, ChannelEmail, ChannelWebhook},
+		},
+		Message:   "broadcast",
+		Broadcast: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.Delivered || len(result.Results) != 3 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	for _, r := range result.Results {
+		if !r.Sent {
+			t.Fatalf("expected every channel to be sent, got %+v", r)
+		}
+	}
+	if tg.sentCount() != 1 || len(mail.received()) != 1 || len(hook.posted) != 1 {
+		t.Fatal("expected every stand-in to receive the notification")
+	}
+	if hook.posted[0].Message != "broadcast" || hook.headers[0].Get("Authorization") != "Bearer test" {
+		t.Fatalf("unexpected webhook request %+v", hook.posted[0])
+	}
+}
+
+func TestDispatcherNoAddress(t *testing.T) {
+	d, _, mail, hook := testDispatcher(t)
+
+	// the recipient has neither a telegram chat nor an email
+	result, err := d.Send(context.Background(), Notification{
+		Recipient: Recipient{Channels: []string{ChannelTelegram, ChannelEmail, ChannelWebhook}},
+		Message:   "hello",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Results) != 3 {
+		t.Fatalf("unexpected results %+v", result.Results)
+	}
+	for _, r := range result.Results[:2] {
+		if !errors.Is(r.Err, ErrNoAddress) || r.Error != ErrNoAddress.Error() {
+			t.Fatalf("expected ErrNoAddress, got %+v", r)
+		}
+	}
+	if !result.Results[2].Sent || len(hook.posted) != 1 || len(mail.received()) != 0 {
+		t.Fatalf("expected the webhook to deliver, got %+v", result.Results[2])
+	}
+}
+
+func TestDispatcherNotDelivered(t *testing.T) {
+	d, tg, mail, _ := testDispatcher(t)
+	tg.fail = true
+	mail.rcptCode = "550"
+	d.Register(&WebhookChannel{Url: newWebhookStandIn(t, http.StatusInternalServerError).URL})
+
+	result, err := d.Send(context.Background(), Notification{
+		Recipient: Recipient{
+			TelegramChatId: 42,
+			Email:          "agent@example.com",
+			Channels:       []string{ChannelTelegram, ChannelEmail, ChannelWebhook, "pager"},
+		},
+		Message: "hello",
+	})
+	if !errors.Is(err, ErrNotDelivered) || !errors.Is(err, ErrUnknownChannel) {
+		t.Fatalf("expected ErrNotDelivered with the channel errors, got %v", err)
+	}
+
+	if result.Delivered || len(result.Results) != 4 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	for _, r := range result.Results {
+		if r.Sent || r.Error == "" {
+			t.Fatalf("expected every channel to fail, got %+v", r)
+		}
+	}
+	if !strings.Contains(result.Results[2].Error, "500") {
+		t.Fatalf("expected the webhook status code, got %q", result.Results[2].Error)
+	}
+}
+
+func TestDispatcherChannels(t *testing.T) {
+	d, tg, mail, _ := testDispatcher(t)
+	d.Preferences = StaticPreferences{"agent-1": {ChannelEmail}}
+
+	// the preference store has higher priority than the default channels
+	_, err := d.Send(context.Background(), Notification{
+		Recipient: Recipient{Id: "agent-1", TelegramChatId: 42, Email: "agent@example.com"},
+		Message:   "hello",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tg.sentCount() != 0 || len(mail.received()) != 1 {
+		t.Fatal("expected the preferred email channel only")
+	}
+
+	d.DefaultChannels = nil
+	_, err = d.Send(context.Background(), Notification{Recipient: Recipient{Id: "agent-2"}, Message: "hello"})
+	if !errors.Is(err, ErrNoChannel) {
+		t.Fatalf("expected ErrNoChannel, got %v", err)
+	}
+}
+
+func TestTelegramChannelRedactsToken(t *testing.T) {
+	tg := newTelegramStandIn(t)
+	tg.Close()
+
+	d := NewDispatcher([]string{ChannelTelegram}, &TelegramChannel{Token: testBotToken, Endpoint: tg.URL})
+	result, err := d.Send(context.Background(), Notification{
+		Recipient: Recipient{TelegramChatId: 42},
+		Message:   "hello",
+	})
+	if err == nil {
+		t.Fatal("expected the closed server to fail the sending")
+	}
+
+	p, _ := json.Marshal(result)
+	if strings.Contains(string(p), testBotToken) || strings.Contains(err.Error(), testBotToken) {
+		t.Fatalf("the bot token is leaked: %s", p)
+	}
+	if !strings.Contains(result.Results[0].Error, "<token>") {
+		t.Fatalf("expected the redacted URL, got %q", result.Results[0].Error)
+	}
+}
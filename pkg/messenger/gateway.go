@@ -0,0 +1,78 @@
+package messenger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// GatewayStatus defines the status of the whatsapp session of the gateway
+type GatewayStatus struct {
+	Phone     string `json:"phone"`
+	Connected bool   `json:"connected"`
+	LoggedIn  bool   `json:"logged_in"`
+}
+
+// ContactInfo defines the contact information of a whatsapp number
+type ContactInfo struct {
+	Phone        string `json:"phone"`
+	JID          string `json:"jid,omitempty"`
+	OnWhatsapp   bool   `json:"on_whatsapp"`
+	InContacts   bool   `json:"in_contacts"`
+	FullName     string `json:"full_name,omitempty"`
+	FirstName    string `json:"first_name,omitempty"`
+	PushName     string `json:"push_name,omitempty"`
+	BusinessName string `json:"business_name,omitempty"`
+	About        string `json:"about,omitempty"`
+}
+
+// GetStatus gets the status of the whatsapp session from the gateway, the retries stop once the context is done
+func (m *Messenger) GetStatus(ctx context.Context) (*GatewayStatus, error) {
+	if m.StatusUrl == "" {
+		return nil, fmt.Errorf("gateway status URL is not configured")
+	}
+
+	var status GatewayStatus
+	err := m.getFromGateway(ctx, m.StatusUrl, &status)
+	if err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+// GetContact gets the contact information of the designated phone from the gateway,
+// the retries stop once the context is done
+func (m *Messenger) GetContact(ctx context.Context, phone string) (*ContactInfo, error) {
+	if m.ContactUrl == "" {
+		return nil, fmt.Errorf("gateway contact URL is not configured")
+	}
+
+	var contact ContactInfo
+	contactUrl := fmt.Sprintf("%s/%s", strings.TrimSuffix(m.ContactUrl, "/"), url.PathEscape(phone))
+	err := m.getFromGateway(ctx, contactUrl, &contact)
+	if err != nil {
+		return nil, err
+	}
+
+	return &contact, nil
+}
+
+// getFromGateway sends GET request to the gateway and extracts the response data to the designated type
+func (m *Messenger) getFromGateway(ctx context.Context, apiUrl string, destType interface{}) error {
+	resp, err := m.callGateway(ctx, http.MethodGet, apiUrl, nil)
+	if err != nil {
+		return err
+	}
+
+	// converts the response data to the designated type
+	dataBytes, err := json.Marshal(resp.Data)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(dataBytes, destType)
+}
@@ -12,9 +12,9 @@ func BuildHttpClient(httpClientTLS bool) *http.Client {
 		tr := &http.Transport{
 			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
 		}
-		return &http.Client{Transport: tr}
+		return &http.Client{Transport: tr, Timeout: defaultGatewayTimeout}
 
 	} else {
-		return &http.Client{}
+		return &http.Client{Timeout: defaultGatewayTimeout}
 	}
 }
@@ -1,11 +1,7 @@
 package messenger
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"github.com/ardihikaru/go-modules/pkg/utils/web"
-	"io"
+	"context"
 	"net/http"
 
 	"github.com/ardihikaru/go-modules/pkg/logger"
@@ -15,12 +11,40 @@ type Messenger struct {
 	Log        *logger.Logger
 	HttpClient *http.Client
 	Url        string
+
+	// StatusUrl and ContactUrl are the optional gateway endpoints to get the session status and the contact info
+	StatusUrl  string
+	ContactUrl string
+
+	// Retry retries the requests failed by network errors or 5xx responses if exists
+	Retry *RetryPolicy
+
+	// Breaker fails fast while the gateway is down if exists
+	Breaker *CircuitBreaker
 }
 
+// media types supported by the whatsapp gateway
+const (
+	MediaImage    = "image"
+	MediaVideo    = "video"
+	MediaAudio    = "audio"
+	MediaVoice    = "voice"
+	MediaDocument = "document"
+)
+
 // PostWhatsappMsg defines the field parameters
 type PostWhatsappMsg struct {
 	Phone   string `json:"phone"`
 	Message string `json:"message"`
+	Media   *Media `json:"media,omitempty"`
+}
+
+// Media defines the media of the message, the data is base64 encoded in JSON
+type Media struct {
+	Type     string `json:"type"`
+	FileName string `json:"file_name,omitempty"`
+	MimeType string `json:"mimetype,omitempty"`
+	Data     []byte `json:"data"`
 }
 
 // WhatsappResponse defines the field parameters
@@ -37,58 +61,45 @@ func InitMessenger(url string, tls bool, log *logger.Logger) *Messenger {
 		HttpClient: BuildHttpClient(tls),
 		Log:        log,
 		Url:        url,
+		Retry:      DefaultRetryPolicy(),
+		Breaker:    NewCircuitBreaker(defaultFailureThreshold, defaultOpenTimeout),
 	}
 }
 
 // SendMsgToWhatsapp sends messages to the Whatsapp chat
 func (m *Messenger) SendMsgToWhatsapp(phone, msg string) (bool, string, error) {
-	var err error
-	var respPayload WhatsappResponse
-
-	// builds auth post form
-	payload := PostWhatsappMsg{
-		Phone:   phone,
-		Message: msg,
-	}
-
-	// prepares the POST form in bytes
-	authFormBytes := new(bytes.Buffer)
-	err = json.NewEncoder(authFormBytes).Encode(payload)
-	if err != nil {
-		return false, "", err
-	}
-
-	// builds POST request with JSON payload
-	req, err := http.NewRequest("POST", m.Url, authFormBytes)
-	if err != nil {
-		return false, "", err
-	}
-	req.Header.Set(web.HeaderContentTypeKey, web.HeaderContentTypeValue)
+	return m.SendMsgToWhatsappContext(context.Background(), phone, msg)
+}
 
-	// sends request
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return false, "", err
-	}
+// SendMsgToWhatsappContext sends messages to the Whatsapp chat, the retries stop once the context is done
+func (m *Messenger) SendMsgToWhatsappContext(ctx context.Context, phone, msg string) (bool, string, error) {
+	return SendMsgToWhatsapp(ctx, m, phone, msg)
+}
 
-	defer resp.Body.Close()
+// SendMediaToWhatsapp sends media messages (e.g. photo, document, voice note, video) to the Whatsapp chat
+// the message is used as the caption of the media
+func (m *Messenger) SendMediaToWhatsapp(phone, msg string, media *Media) (bool, string, error) {
+	return m.SendMediaToWhatsappContext(context.Background(), phone, msg, media)
+}
 
-	// read response body
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return false, "", err
-	}
+// SendMediaToWhatsappContext sends media messages to the Whatsapp chat, the retries stop once the context is done
+func (m *Messenger) SendMediaToWhatsappContext(ctx context.Context, phone, msg string,
+	media *Media) (bool, string, error) {
+	return SendMediaToWhatsapp(ctx, m, phone, msg, media)
+}
 
-	// Convert response body to WhatsappResponse struct
-	err = json.Unmarshal(bodyBytes, &respPayload)
+// SendToWhatsapp sends the text or media message to the Whatsapp chat, and returns the ID of the sent message
+// the ID is taken from the `msg_id` field of the response data, if any
+func (m *Messenger) SendToWhatsapp(ctx context.Context, msg PostWhatsappMsg) (*SendResult, error) {
+	resp, err := m.callGateway(ctx, http.MethodPost, m.Url, msg)
 	if err != nil {
-		return false, "", err
+		return nil, err
 	}
 
-	// if data is nil, returns an error
-	if respPayload.Data == nil {
-		return false, "", fmt.Errorf("response data is empty")
+	result := &SendResult{Message: SentConfirmation}
+	if data, ok := resp.Data.(map[string]interface{}); ok {
+		result.MsgId, _ = data["msg_id"].(string)
 	}
 
-	return true, "chat has been replied", nil
+	return result, nil
 }
@@ -0,0 +1,70 @@
+package messenger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSendToWhatsappConfirmation(t *testing.T) {
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"code":0,"message":"message is queued by the gateway","data":{"msg_id":"3EB0"}}`)
+	}))
+	defer gateway.Close()
+
+	m := &Messenger{HttpClient: gateway.Client(), Url: gateway.URL}
+	for name, sender := range map[string]Sender{"messenger": m, "recorder": &RecordingSender{}} {
+		result, err := sender.SendToWhatsapp(context.Background(), PostWhatsappMsg{Phone: "+62811", Message: "hi"})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if result.Message != SentConfirmation {
+			t.Fatalf("%s: expected the fixed confirmation, got %q", name, result.Message)
+		}
+	}
+
+	sent, reply, err := SendMsgToWhatsapp(context.Background(), m, "+62811", "hi")
+	if err != nil || !sent || reply != SentConfirmation {
+		t.Fatalf("unexpected result %v, %q, %v", sent, reply, err)
+	}
+}
+
+func TestGatewayQueriesHonorContext(t *testing.T) {
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/contact/+62811" {
+			_, _ = fmt.Fprint(w, `{"data":{"phone":"+62811","on_whatsapp":true}}`)
+			return
+		}
+
+		// a slow status
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer gateway.Close()
+
+	m := &Messenger{
+		HttpClient: gateway.Client(),
+		StatusUrl:  gateway.URL + "/status",
+		ContactUrl: gateway.URL + "/contact",
+		Retry:      &RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := m.GetStatus(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("expected the status query to be bounded by the context, got %v after %s", err, time.Since(start))
+	}
+
+	contact, err := m.GetContact(context.Background(), "+62811")
+	if err != nil || !contact.OnWhatsapp || contact.Phone != "+62811" {
+		t.Fatalf("unexpected contact %+v, %v", contact, err)
+	}
+}
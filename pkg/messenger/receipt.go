@@ -0,0 +1,36 @@
+package messenger
+
+import (
+	"time"
+)
+
+// delivery receipts of the sent whatsapp messages, in order of progress
+const (
+	ReceiptSent      = "sent"
+	ReceiptDelivered = "delivered"
+	ReceiptRead      = "read"
+	ReceiptPlayed    = "played" // a voice note or a video has been played
+)
+
+// receiptRanks defines the progress of each receipt
+var receiptRanks = map[string]int{
+	ReceiptSent:      1,
+	ReceiptDelivered: 2,
+	ReceiptRead:      3,
+	ReceiptPlayed:    4,
+}
+
+// DeliveryReceipt defines the delivery status of the sent whatsapp messages, as reported by the gateway
+type DeliveryReceipt struct {
+	PhoneOwner string    `json:"phone_owner"`
+	MsgIds     []string  `json:"msg_ids"`
+	Phone      string    `json:"phone"` // the recipient of the messages
+	Receipt    string    `json:"receipt"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// ReceiptRank returns the progress of the receipt, zero if it is unknown
+// receipts may arrive out of order, hence a receipt with a lower rank must not replace the current one
+func ReceiptRank(receipt string) int {
+	return receiptRanks[receipt]
+}
@@ -0,0 +1,110 @@
+package messenger
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// SentMessage defines a message captured by RecordingSender
+type SentMessage struct {
+	Phone   string
+	Message string
+	Media   *Media
+}
+
+// RecordingSender is a fake Sender which records the sent messages instead of sending them, e.g. for tests
+// the zero value is ready to use, and reports every message as sent
+type RecordingSender struct {
+	// Err fails every sending if exists
+	Err error
+
+	// Status is returned by GetStatus
+	Status GatewayStatus
+
+	// Contacts are returned by GetContact, an unknown phone is reported as not on whatsapp
+	Contacts map[string]*ContactInfo
+
+	mu   sync.Mutex
+	sent []SentMessage
+}
+
+// SendToWhatsapp records the text or media message, the message ID is its sequence number, e.g. "msg-1"
+func (s *RecordingSender) SendToWhatsapp(ctx context.Context, msg PostWhatsappMsg) (*SendResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	seq, err := s.add(SentMessage{Phone: msg.Phone, Message: msg.Message, Media: msg.Media})
+	if err != nil {
+		return nil, err
+	}
+
+	return &SendResult{MsgId: fmt.Sprintf("msg-%d", seq), Message: SentConfirmation}, nil
+}
+
+// GetStatus returns the configured status
+func (s *RecordingSender) GetStatus(ctx context.Context) (*GatewayStatus, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	status := s.Status
+
+	return &status, nil
+}
+
+// GetContact returns the configured contact of the designated phone
+func (s *RecordingSender) GetContact(ctx context.Context, phone string) (*ContactInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if s.Err != nil {
+		return nil, s.Err
+	}
+
+	if contact, ok := s.Contacts[phone]; ok {
+		return contact, nil
+	}
+
+	return &ContactInfo{Phone: phone}, nil
+}
+
+// Sent returns a copy of the recorded messages, the oldest first
+func (s *RecordingSender) Sent() []SentMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sent := make([]SentMessage, len(s.sent))
+	copy(sent, s.sent)
+
+	return sent
+}
+
+// Reset removes the recorded messages
+func (s *RecordingSender) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent = nil
+}
+
+// add records the message, and returns its sequence number (starts from 1)
+func (s *RecordingSender) add(msg SentMessage) (int, error) {
+	if s.Err != nil {
+		return 0, s.Err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, msg)
+
+	return len(s.sent), nil
+}
+
+// ensures that RecordingSender implements Sender
+var _ Sender = (*RecordingSender)(nil)
@@ -0,0 +1,57 @@
+package messenger
+
+import (
+	"context"
+)
+
+// Sender defines the transport used by the telegram bridge to reach the whatsapp session
+//
+// Messenger sends over HTTP to the whatsapp gateway, while wawebhook.LocalSender sends in-process
+// when the whatsapp bot runs in the same binary.
+type Sender interface {
+	// SendToWhatsapp sends a text or media message, and returns the ID of the sent whatsapp message if reported
+	// it returns an error if the message is not sent
+	SendToWhatsapp(ctx context.Context, msg PostWhatsappMsg) (*SendResult, error)
+
+	// GetStatus gets the status of the whatsapp session
+	GetStatus(ctx context.Context) (*GatewayStatus, error)
+
+	// GetContact gets the contact information of the designated phone
+	GetContact(ctx context.Context, phone string) (*ContactInfo, error)
+}
+
+// SentConfirmation is the message replied to the agent once the message is sent, by every Sender
+const SentConfirmation = "chat has been replied"
+
+// SendResult defines the result of a sent message
+type SendResult struct {
+	// MsgId is the ID of the sent whatsapp message, empty if the gateway does not report it
+	MsgId string
+
+	// Message is the message to reply to the agent, i.e. SentConfirmation
+	Message string
+}
+
+// SendMsgToWhatsapp sends a text message via the sender, it returns whether it is sent and the message to reply
+// to the agent
+func SendMsgToWhatsapp(ctx context.Context, s Sender, phone, msg string) (bool, string, error) {
+	return send(ctx, s, PostWhatsappMsg{Phone: phone, Message: msg})
+}
+
+// SendMediaToWhatsapp sends a media message via the sender, the message is used as the caption of the media
+func SendMediaToWhatsapp(ctx context.Context, s Sender, phone, msg string, media *Media) (bool, string, error) {
+	return send(ctx, s, PostWhatsappMsg{Phone: phone, Message: msg, Media: media})
+}
+
+// send sends the message via the sender
+func send(ctx context.Context, s Sender, msg PostWhatsappMsg) (bool, string, error) {
+	result, err := s.SendToWhatsapp(ctx, msg)
+	if err != nil {
+		return false, "", err
+	}
+
+	return true, result.Message, nil
+}
+
+// ensures that Messenger implements Sender
+var _ Sender = (*Messenger)(nil)
@@ -0,0 +1,299 @@
+package messenger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ardihikaru/go-modules/pkg/utils/web"
+)
+
+// defaults of the gateway transport
+const (
+	defaultGatewayTimeout   = 30 * time.Second
+	defaultMaxAttempts      = 3
+	defaultRetryBackoff     = 500 * time.Millisecond
+	defaultMaxRetryBackoff  = 5 * time.Second
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned without calling the gateway while the circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open: whatsapp gateway is unavailable")
+
+// GatewayError defines the error response of the whatsapp gateway
+type GatewayError struct {
+	StatusCode int
+	Code       int64  // the `code` field of WhatsappResponse
+	ErrorMsg   string // the `error` field of WhatsappResponse
+	Message    string // the `message` field of WhatsappResponse
+	Body       string // the raw response body, e.g. if it is not a WhatsappResponse
+}
+
+// Error returns the error message
+func (e *GatewayError) Error() string {
+	msg := e.ErrorMsg
+	if msg == "" {
+		msg = e.Message
+	}
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+
+	return fmt.Sprintf("whatsapp gateway responded [%d] with code [%d]: %s", e.StatusCode, e.Code, msg)
+}
+
+// Retryable checks whether the request may succeed on retry, i.e. the gateway failed with a 5xx response
+func (e *GatewayError) Retryable() bool {
+	return e.StatusCode >= http.StatusInternalServerError
+}
+
+// RetryPolicy defines how the failed requests are retried, only network errors and 5xx responses are retried
+// note that a retried message may be delivered twice, if the gateway failed after sending it
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts including the first one, less than 2 disables the retry
+	MaxAttempts int
+
+	// Backoff is the delay before the first retry, it is doubled on each retry
+	Backoff time.Duration
+
+	// MaxBackoff caps the delay between the retries
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy builds the default retry policy
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: defaultMaxAttempts,
+		Backoff:     defaultRetryBackoff,
+		MaxBackoff:  defaultMaxRetryBackoff,
+	}
+}
+
+// attempts returns the maximum number of attempts
+func (p *RetryPolicy) attempts() int {
+	if p == nil || p.MaxAttempts < 1 {
+		return 1
+	}
+
+	return p.MaxAttempts
+}
+
+// backoff returns the delay before the designated retry (starts from 1)
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < retry; i++ {
+		delay *= 2
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+
+	return delay
+}
+
+// CircuitBreaker fails fast while the gateway is down
+//
+// The circuit opens after the designated number of consecutive failures, and rejects every request until the
+// open timeout elapses. Then a single trial request is allowed (half-open): its success closes the circuit,
+// and its failure opens it again.
+type CircuitBreaker struct {
+	FailureThreshold int
+	OpenTimeout      time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// NewCircuitBreaker builds the circuit breaker
+func NewCircuitBreaker(failureThreshold int, openTimeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{FailureThreshold: failureThreshold, OpenTimeout: openTimeout}
+}
+
+// allow checks whether the request is allowed
+func (cb *CircuitBreaker) allow() bool {
+	if cb == nil {
+		return true
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.openedAt.IsZero() {
+		return true
+	}
+	if cb.trial || time.Since(cb.openedAt) < cb.OpenTimeout {
+		return false
+	}
+
+	// half-open: allows a single trial request
+	cb.trial = true
+
+	return true
+}
+
+// record records the result of the request
+func (cb *CircuitBreaker) record(success bool) {
+	if cb == nil {
+		return
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if success {
+		cb.failures = 0
+		cb.openedAt = time.Time{}
+		cb.trial = false
+		return
+	}
+
+	cb.failures++
+	if cb.trial || cb.failures >= cb.FailureThreshold {
+		cb.openedAt = time.Now()
+		cb.trial = false
+	}
+}
+
+// release releases the trial request without recording its result
+func (cb *CircuitBreaker) release() {
+	if cb == nil {
+		return
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.trial = false
+}
+
+// Open checks whether the circuit is open, i.e. the requests are rejected
+func (cb *CircuitBreaker) Open() bool {
+	if cb == nil {
+		return false
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return !cb.openedAt.IsZero() && time.Since(cb.openedAt) < cb.OpenTimeout
+}
+
+// callGateway sends the request to the gateway with the retry policy and the circuit breaker
+// the response of a 2xx request is returned, otherwise the error is either a *GatewayError or a network error
+func (m *Messenger) callGateway(ctx context.Context, method, apiUrl string, payload interface{}) (*WhatsappResponse,
+	error) {
+	var body []byte
+	if payload != nil {
+		var err error
+		body, err = json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= m.Retry.attempts(); attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(m.Retry.backoff(attempt - 1)):
+			}
+		}
+
+		if !m.Breaker.allow() {
+			return nil, ErrCircuitOpen
+		}
+
+		resp, err := m.doRequest(ctx, method, apiUrl, body)
+		if err == nil {
+			m.Breaker.record(true)
+			return resp, nil
+		}
+		lastErr = err
+
+		// a 4xx response means that the gateway is up, and the request would fail again
+		var gwErr *GatewayError
+		if errors.As(err, &gwErr) && !gwErr.Retryable() {
+			m.Breaker.record(true)
+			return nil, err
+		}
+
+		// the request cancelled by the caller says nothing about the gateway, hence it is neither recorded nor retried
+		if ctx.Err() != nil {
+			m.Breaker.release()
+			return nil, err
+		}
+		m.Breaker.record(false)
+
+		if m.Log != nil {
+			m.Log.Warn(fmt.Sprintf("whatsapp gateway request failed (attempt %d/%d) -> %s", attempt,
+				m.Retry.attempts(), err.Error()))
+		}
+	}
+
+	return nil, lastErr
+}
+
+// doRequest sends a single request to the gateway
+func (m *Messenger) doRequest(ctx context.Context, method, apiUrl string, body []byte) (*WhatsappResponse, error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, apiUrl, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set(web.HeaderContentTypeKey, web.HeaderContentTypeValue)
+	}
+
+	resp, err := m.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var respPayload WhatsappResponse
+	jsonErr := json.Unmarshal(bodyBytes, &respPayload)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &GatewayError{
+			StatusCode: resp.StatusCode,
+			Code:       respPayload.Code,
+			ErrorMsg:   respPayload.ErrorMsg,
+			Message:    respPayload.Message,
+			Body:       string(bodyBytes),
+		}
+	}
+	if jsonErr != nil {
+		return nil, fmt.Errorf("invalid gateway response: %w", jsonErr)
+	}
+
+	return &respPayload, nil
+}
+
+// httpClient returns the configured client, or a client with the default timeout
+func (m *Messenger) httpClient() *http.Client {
+	if m.HttpClient != nil {
+		return m.HttpClient
+	}
+
+	return &http.Client{Timeout: defaultGatewayTimeout}
+}
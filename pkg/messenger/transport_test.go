@@ -0,0 +1,215 @@
+package messenger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGateway responds with the designated responses in order, the last one is repeated
+type fakeGateway struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	responses []fakeResponse
+	calls     int
+}
+
+// fakeResponse defines a response of fakeGateway
+type fakeResponse struct {
+	status int
+	body   string
+}
+
+func newFakeGateway(t *testing.T, responses ...fakeResponse) *fakeGateway {
+	gw := &fakeGateway{responses: responses}
+	gw.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gw.mu.Lock()
+		resp := gw.responses[len(gw.responses)-1]
+		if gw.calls < len(gw.responses) {
+			resp = gw.responses[gw.calls]
+		}
+		gw.calls++
+		gw.mu.Unlock()
+
+		w.WriteHeader(resp.status)
+		_, _ = fmt.Fprint(w, resp.body)
+	}))
+	t.Cleanup(gw.Close)
+
+	return gw
+}
+
+// called returns the number of the received requests
+func (gw *fakeGateway) called() int {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+
+	return gw.calls
+}
+
+// newTestMessenger builds the messenger of the gateway, without any backoff between the retries
+func newTestMessenger(gw *fakeGateway, maxAttempts int, breaker *CircuitBreaker) *Messenger {
+	return &Messenger{
+		HttpClient: gw.Client(),
+		Url:        gw.URL,
+		Retry:      &RetryPolicy{MaxAttempts: maxAttempts, Backoff: time.Millisecond, MaxBackoff: time.Millisecond},
+		Breaker:    breaker,
+	}
+}
+
+var (
+	okResponse          = fakeResponse{http.StatusOK, `{"data":{"msg_id":"3EB0"}}`}
+	unavailableResponse = fakeResponse{http.StatusServiceUnavailable, `{"error":"session is not connected"}`}
+	badRequestResponse  = fakeResponse{http.StatusBadRequest, `{"code":1001,"error":"invalid phone"}`}
+)
+
+func TestCallGatewayRetries(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		responses   []fakeResponse
+		maxAttempts int
+		calls       int
+		status      int // the status of the returned GatewayError, zero if it succeeds
+	}{
+		{"5xx is retried up to the max attempts", []fakeResponse{unavailableResponse}, 3, 3, 503},
+		{"5xx is retried until it succeeds", []fakeResponse{unavailableResponse, okResponse}, 3, 2, 0},
+		{"retry is disabled", []fakeResponse{unavailableResponse}, 1, 1, 503},
+		{"4xx is not retried", []fakeResponse{badRequestResponse}, 3, 1, 400},
+		{"404 is not retried", []fakeResponse{{http.StatusNotFound, ""}}, 3, 1, 404},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			gw := newFakeGateway(t, tc.responses...)
+			m := newTestMessenger(gw, tc.maxAttempts, nil)
+
+			result, err := m.SendToWhatsapp(context.Background(), PostWhatsappMsg{Phone: "+62811", Message: "hi"})
+			if calls := gw.called(); calls != tc.calls {
+				t.Fatalf("expected %d requests, got %d", tc.calls, calls)
+			}
+
+			if tc.status == 0 {
+				if err != nil || result.MsgId != "3EB0" {
+					t.Fatalf("expected the message to be sent, got %+v, %v", result, err)
+				}
+				return
+			}
+			var gwErr *GatewayError
+			if !errors.As(err, &gwErr) || gwErr.StatusCode != tc.status {
+				t.Fatalf("expected a gateway error [%d], got %v", tc.status, err)
+			}
+		})
+	}
+}
+
+func TestGatewayErrorKeepsResponse(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		response fakeResponse
+		want     GatewayError
+	}{
+		{"json", fakeResponse{http.StatusUnprocessableEntity, `{"code":1001,"error":"invalid phone","message":"x"}`},
+			GatewayError{StatusCode: 422, Code: 1001, ErrorMsg: "invalid phone", Message: "x",
+				Body: `{"code":1001,"error":"invalid phone","message":"x"}`}},
+		{"not json", fakeResponse{http.StatusBadGateway, "upstream is down"},
+			GatewayError{StatusCode: 502, Body: "upstream is down"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newTestMessenger(newFakeGateway(t, tc.response), 1, nil)
+
+			_, err := m.SendToWhatsapp(context.Background(), PostWhatsappMsg{Phone: "+62811", Message: "hi"})
+			var gwErr *GatewayError
+			if !errors.As(err, &gwErr) || *gwErr != tc.want {
+				t.Fatalf("expected %+v, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	gw := newFakeGateway(t, unavailableResponse, unavailableResponse, okResponse)
+	breaker := NewCircuitBreaker(2, 50*time.Millisecond)
+	m := newTestMessenger(gw, 1, breaker)
+	send := func() error {
+		_, err := m.SendToWhatsapp(context.Background(), PostWhatsappMsg{Phone: "+62811", Message: "hi"})
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		var gwErr *GatewayError
+		if err := send(); !errors.As(err, &gwErr) {
+			t.Fatalf("expected the gateway error on failure %d, got %v", i+1, err)
+		}
+	}
+	if !breaker.Open() {
+		t.Fatal("expected the circuit to be open after the threshold")
+	}
+
+	// rejected without calling the gateway until the cooldown ends
+	if err := send(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if calls := gw.called(); calls != 2 {
+		t.Fatalf("expected the gateway not to be called while open, got %d requests", calls)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if err := send(); err != nil {
+		t.Fatalf("expected the trial request to succeed after the cooldown, got %v", err)
+	}
+	if breaker.Open() {
+		t.Fatal("expected the circuit to be closed by the successful trial")
+	}
+}
+
+func TestCallGatewayIgnoresCallerCancellation(t *testing.T) {
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// a slow gateway, the caller gives up first
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer gateway.Close()
+
+	m := &Messenger{
+		HttpClient: gateway.Client(),
+		Url:        gateway.URL,
+		Retry:      &RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond},
+		Breaker:    NewCircuitBreaker(1, time.Minute),
+	}
+
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		_, err := m.SendToWhatsapp(ctx, PostWhatsappMsg{Phone: "+62811", Message: "hello"})
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected the deadline to be exceeded, got %v", err)
+		}
+	}
+	if m.Breaker.Open() {
+		t.Fatal("the caller cancellations must not open the circuit")
+	}
+}
+
+func TestCircuitBreakerReleasesCancelledTrial(t *testing.T) {
+	cb := NewCircuitBreaker(1, time.Millisecond)
+	cb.record(false)
+	time.Sleep(2 * time.Millisecond)
+
+	if !cb.allow() {
+		t.Fatal("expected the trial request to be allowed")
+	}
+	if cb.allow() {
+		t.Fatal("expected a single trial request")
+	}
+
+	cb.release()
+	if !cb.allow() {
+		t.Fatal("expected another trial request once the cancelled one is released")
+	}
+}
@@ -0,0 +1,189 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// lockRetryInterval defines the base interval of the acquisition attempts of Lock, a random jitter is added
+const lockRetryInterval = 50 * time.Millisecond
+
+var (
+	ErrLockNotAcquired = errors.New("redis: lock is held by another owner")
+	ErrLockNotHeld     = errors.New("redis: lock is no longer held")
+)
+
+var (
+	// unlockScript deletes the lock only if it is still held by the token
+	unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+	// extendScript extends the lock only if it is still held by the token
+	extendScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+)
+
+// Lock is a distributed lock held by a unique token, it is extended automatically until it is released
+// if the extension fails (e.g. the lock has expired while redis was unreachable), Lost is closed
+type Lock struct {
+	rdb   *Redis
+	key   string
+	token string
+	ttl   time.Duration
+
+	once sync.Once
+	stop chan struct{}
+	done chan struct{}
+	lost chan struct{}
+}
+
+// TryLock acquires the lock of the key once, returns ErrLockNotAcquired if it is held by another owner
+// the ttl bounds how long the lock outlives its owner, e.g. if the process crashes
+func (r *Redis) TryLock(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	if ttl < time.Millisecond {
+		return nil, fmt.Errorf("invalid lock ttl [%s], at least 1ms is required", ttl)
+	}
+
+	client, err := r.withContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := lockToken()
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := client.SetNX(key, token, ttl).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrLockNotAcquired
+	}
+
+	l := &Lock{
+		rdb:   r,
+		key:   key,
+		token: token,
+		ttl:   ttl,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+		lost:  make(chan struct{}),
+	}
+	go l.keepAlive()
+
+	return l, nil
+}
+
+// Lock acquires the lock of the key, waiting until it is released by its owner or the context is done
+// a wait timeout is set by the context deadline
+func (r *Redis) Lock(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	for {
+		l, err := r.TryLock(ctx, key, ttl)
+		if !errors.Is(err, ErrLockNotAcquired) {
+			return l, err
+		}
+
+		timer := time.NewTimer(lockRetryInterval + jitter(lockRetryInterval))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Key returns the key of the lock
+func (l *Lock) Key() string {
+	return l.key
+}
+
+// Lost is closed if the lock could not be extended, i.e. it may be held by another owner
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Unlock releases the lock, returns ErrLockNotHeld if it has expired or been acquired by another owner
+func (l *Lock) Unlock() error {
+	err := ErrLockNotHeld
+	l.once.Do(func() {
+		close(l.stop)
+		<-l.done
+
+		var n int64
+		n, err = unlockScript.Run(l.rdb.Client, []string{l.key}, l.token).Int64()
+		if err == nil && n == 0 {
+			err = ErrLockNotHeld
+		}
+	})
+
+	return err
+}
+
+// keepAlive extends the lock every third of its ttl, until it is released or lost
+// a failed extension is retried on the next tick, but once the ttl has passed since the last successful one,
+// the lock may have expired and been acquired by another owner, hence it is lost
+func (l *Lock) keepAlive() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	// TryLock has just set the expiration
+	lastExtended := time.Now()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			// the expiration counts from the request, which is no later than redis applies it
+			requested := time.Now()
+			n, err := extendScript.Run(l.rdb.Client, []string{l.key}, l.token, l.ttl.Milliseconds()).Int64()
+			if err == nil && n > 0 {
+				lastExtended = requested
+				continue
+			}
+			if err == nil || time.Since(lastExtended) >= l.ttl {
+				close(l.lost)
+				return
+			}
+		}
+	}
+}
+
+// lockToken generates the random token of the lock owner
+func lockToken() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// jitter returns a random duration up to the designated maximum
+func jitter(max time.Duration) time.Duration {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0
+	}
+
+	return time.Duration(n.Int64())
+}
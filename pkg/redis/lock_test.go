@@ -0,0 +1,179 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLockMutualExclusion(t *testing.T) {
+	r, _ := newTestRedis(t)
+
+	const workers = 20
+	var holders, maxHolders, acquired int32
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			l, err := r.Lock(ctx, "job", time.Second)
+			if err != nil {
+				t.Errorf("failed to acquire the lock: %v", err)
+				return
+			}
+
+			n := atomic.AddInt32(&holders, 1)
+			for {
+				max := atomic.LoadInt32(&maxHolders)
+				if n <= max || atomic.CompareAndSwapInt32(&maxHolders, max, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&holders, -1)
+			atomic.AddInt32(&acquired, 1)
+
+			err = l.Unlock()
+			if err != nil {
+				t.Errorf("failed to release the lock: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if maxHolders != 1 {
+		t.Fatalf("expected at most 1 holder at a time, got %d", maxHolders)
+	}
+	if acquired != workers {
+		t.Fatalf("expected %d acquisitions, got %d", workers, acquired)
+	}
+}
+
+func TestTryLock(t *testing.T) {
+	r, _ := newTestRedis(t)
+	ctx := context.Background()
+
+	l, err := r.TryLock(ctx, "job", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = r.TryLock(ctx, "job", time.Second)
+	if !errors.Is(err, ErrLockNotAcquired) {
+		t.Fatalf("expected ErrLockNotAcquired, got %v", err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, err = r.Lock(waitCtx, "job", time.Second)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the wait timeout, got %v", err)
+	}
+
+	err = l.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = l.Unlock()
+	if !errors.Is(err, ErrLockNotHeld) {
+		t.Fatalf("expected ErrLockNotHeld on the second release, got %v", err)
+	}
+
+	l, err = r.TryLock(ctx, "job", time.Second)
+	if err != nil {
+		t.Fatalf("expected the released lock to be acquired, got %v", err)
+	}
+	_ = l.Unlock()
+}
+
+func TestLockExtension(t *testing.T) {
+	r, mr := newTestRedis(t)
+	ttl := 300 * time.Millisecond
+
+	l, err := r.TryLock(context.Background(), "job", ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = l.Unlock() }()
+
+	// the stand-in only expires the keys on fast-forward, i.e. 1.2s passes on redis in total
+	for i := 0; i < 6; i++ {
+		mr.FastForward(ttl * 2 / 3)
+		time.Sleep(ttl / 2)
+		if !mr.Exists("job") {
+			t.Fatalf("the lock expired after %s", time.Duration(i+1)*ttl*2/3)
+		}
+	}
+
+	select {
+	case <-l.Lost():
+		t.Fatal("the lock must not be lost while it is extended")
+	default:
+	}
+}
+
+func TestLockLost(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(r *Redis, key string)
+	}{
+		{"deleted", func(r *Redis, key string) { _, _ = r.Delete(context.Background(), key) }},
+		{"replaced", func(r *Redis, key string) { _ = r.Client.Set(key, "another owner", 0).Err() }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, mr := newTestRedis(t)
+
+			l, err := r.TryLock(context.Background(), "job", 150*time.Millisecond)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tt.change(r, "job")
+			select {
+			case <-l.Lost():
+			case <-time.After(time.Second):
+				t.Fatal("expected the lock to be lost")
+			}
+
+			err = l.Unlock()
+			if !errors.Is(err, ErrLockNotHeld) {
+				t.Fatalf("expected ErrLockNotHeld, got %v", err)
+			}
+			if tt.name == "replaced" {
+				if v, _ := mr.Get("job"); v != "another owner" {
+					t.Fatalf("the lock of another owner must not be released, got %q", v)
+				}
+			}
+		})
+	}
+}
+
+func TestLockLostWhileUnreachable(t *testing.T) {
+	r, mr := newTestRedis(t)
+	ttl := 150 * time.Millisecond
+
+	l, err := r.TryLock(context.Background(), "job", ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mr.Close()
+	start := time.Now()
+	select {
+	case <-l.Lost():
+		if elapsed := time.Since(start); elapsed < ttl*2/3 {
+			t.Fatalf("the lock is lost too early, after %s", elapsed)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the lock to be lost once the ttl has passed without an extension")
+	}
+}
@@ -0,0 +1,290 @@
+package redis
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// DefaultEnvPrefix defines the default prefix of the environment variables of the options, see OptionsFromEnv
+const DefaultEnvPrefix = "REDIS_"
+
+// Options defines the redis connection options, the zero values use the go-redis defaults
+type Options struct {
+	// Addr is the address of a single node, ignored if SentinelMaster is set
+	Addr     string
+	Password string
+	DB       int
+
+	// SentinelMaster and SentinelAddrs enable the sentinel mode: the master is resolved by the sentinels,
+	// and the client follows it on failover; go-redis logs the resolved master on each switch
+	SentinelMaster string
+	SentinelAddrs  []string
+
+	// TLS enables TLS if exists, on both the sentinels and the master
+	TLS *TLSOptions
+
+	PoolSize int
+
+	// MinIdleConns is not supported by the go-redis failover client, it is rejected in the sentinel mode
+	MinIdleConns int
+
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// MaxRetries is the number of retries of a failed command, zero disables the retries
+	MaxRetries int
+
+	// MinRetryBackoff and MaxRetryBackoff are not supported by the go-redis failover client,
+	// they are rejected in the sentinel mode
+	MinRetryBackoff time.Duration
+	MaxRetryBackoff time.Duration
+}
+
+// TLSOptions defines the TLS options, the system CAs are used if the CA file is empty
+type TLSOptions struct {
+	CAFile   string
+	CertFile string // the client certificate, required by mutual TLS only
+	KeyFile  string
+
+	ServerName         string // overrides the server name verified against the certificate
+	InsecureSkipVerify bool
+}
+
+// OptionsFromEnv loads the options from the environment variables of the designated prefix (e.g. DefaultEnvPrefix):
+// ADDR, PASSWORD, DB, SENTINEL_MASTER, SENTINEL_ADDRS (comma separated), TLS_ENABLED, TLS_CA_FILE, TLS_CERT_FILE,
+// TLS_KEY_FILE, TLS_SERVER_NAME, TLS_INSECURE_SKIP_VERIFY, POOL_SIZE, MIN_IDLE_CONNS, DIAL_TIMEOUT, READ_TIMEOUT,
+// WRITE_TIMEOUT, MAX_RETRIES, MIN_RETRY_BACKOFF and MAX_RETRY_BACKOFF; the durations are formatted as "5s"
+func OptionsFromEnv(prefix string) (*Options, error) {
+	env := envReader{prefix: prefix}
+
+	opts := &Options{
+		Addr:           env.str("ADDR"),
+		Password:       env.str("PASSWORD"),
+		DB:             env.int("DB"),
+		SentinelMaster: env.str("SENTINEL_MASTER"),
+		PoolSize:       env.int("POOL_SIZE"),
+		MinIdleConns:   env.int("MIN_IDLE_CONNS"),
+		DialTimeout:    env.duration("DIAL_TIMEOUT"),
+		ReadTimeout:    env.duration("READ_TIMEOUT"),
+		WriteTimeout:   env.duration("WRITE_TIMEOUT"),
+		MaxRetries:     env.int("MAX_RETRIES"),
+	}
+	opts.MinRetryBackoff = env.duration("MIN_RETRY_BACKOFF")
+	opts.MaxRetryBackoff = env.duration("MAX_RETRY_BACKOFF")
+
+	for _, addr := range strings.Split(env.str("SENTINEL_ADDRS"), ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			opts.SentinelAddrs = append(opts.SentinelAddrs, addr)
+		}
+	}
+
+	tlsOpts := TLSOptions{
+		CAFile:             env.str("TLS_CA_FILE"),
+		CertFile:           env.str("TLS_CERT_FILE"),
+		KeyFile:            env.str("TLS_KEY_FILE"),
+		ServerName:         env.str("TLS_SERVER_NAME"),
+		InsecureSkipVerify: env.bool("TLS_INSECURE_SKIP_VERIFY"),
+	}
+	if env.bool("TLS_ENABLED") || tlsOpts != (TLSOptions{}) {
+		opts.TLS = &tlsOpts
+	}
+
+	if env.err != nil {
+		return nil, env.err
+	}
+
+	return opts, nil
+}
+
+// envReader reads the environment variables of the prefix, and keeps the first parsing error
+type envReader struct {
+	prefix string
+	err    error
+}
+
+// str reads the string variable
+func (e *envReader) str(name string) string {
+	return strings.TrimSpace(os.Getenv(e.prefix + name))
+}
+
+// int reads the integer variable, zero if it is empty
+func (e *envReader) int(name string) int {
+	v := e.str(name)
+	if v == "" {
+		return 0
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil && e.err == nil {
+		e.err = fmt.Errorf("invalid integer [%s] of %s%s", v, e.prefix, name)
+	}
+
+	return n
+}
+
+// bool reads the boolean variable, false if it is empty
+func (e *envReader) bool(name string) bool {
+	v := e.str(name)
+	if v == "" {
+		return false
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil && e.err == nil {
+		e.err = fmt.Errorf("invalid boolean [%s] of %s%s", v, e.prefix, name)
+	}
+
+	return b
+}
+
+// duration reads the duration variable, zero if it is empty
+func (e *envReader) duration(name string) time.Duration {
+	v := e.str(name)
+	if v == "" {
+		return 0
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil && e.err == nil {
+		e.err = fmt.Errorf("invalid duration [%s] of %s%s", v, e.prefix, name)
+	}
+
+	return d
+}
+
+// tlsConfig builds the TLS config, nil if TLS is disabled
+func (o *Options) tlsConfig() (*tls.Config, error) {
+	if o.TLS == nil {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         o.TLS.ServerName,
+		InsecureSkipVerify: o.TLS.InsecureSkipVerify,
+	}
+
+	if o.TLS.CAFile != "" {
+		ca, err := os.ReadFile(o.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the CA file: %w", err)
+		}
+
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate is found in the CA file [%s]", o.TLS.CAFile)
+		}
+	}
+
+	if o.TLS.CertFile != "" || o.TLS.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.TLS.CertFile, o.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// newClient builds the client of the options, either a single node or a sentinel failover client
+func (o *Options) newClient() (*redis.Client, error) {
+	tlsConfig, err := o.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if o.SentinelMaster != "" {
+		if len(o.SentinelAddrs) == 0 {
+			return nil, fmt.Errorf("no sentinel address of master [%s]", o.SentinelMaster)
+		}
+
+		// the failover client silently drops these options
+		if o.MinIdleConns != 0 || o.MinRetryBackoff != 0 || o.MaxRetryBackoff != 0 {
+			return nil, fmt.Errorf("min idle conns and retry backoffs are not supported in the sentinel mode")
+		}
+
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName: o.SentinelMaster,
+			// the failover client reorders and extends the addresses in place
+			SentinelAddrs: append([]string(nil), o.SentinelAddrs...),
+			Password:      o.Password,
+			DB:            o.DB,
+			MaxRetries:    o.MaxRetries,
+			DialTimeout:   o.DialTimeout,
+			ReadTimeout:   o.ReadTimeout,
+			WriteTimeout:  o.WriteTimeout,
+			PoolSize:      o.PoolSize,
+			TLSConfig:     tlsConfig,
+		}), nil
+	}
+
+	if o.Addr == "" {
+		return nil, fmt.Errorf("either the redis address or the sentinel master is required")
+	}
+
+	return redis.NewClient(&redis.Options{
+		Addr:            o.Addr,
+		Password:        o.Password,
+		DB:              o.DB,
+		MaxRetries:      o.MaxRetries,
+		MinRetryBackoff: o.MinRetryBackoff,
+		MaxRetryBackoff: o.MaxRetryBackoff,
+		DialTimeout:     o.DialTimeout,
+		ReadTimeout:     o.ReadTimeout,
+		WriteTimeout:    o.WriteTimeout,
+		PoolSize:        o.PoolSize,
+		MinIdleConns:    o.MinIdleConns,
+		TLSConfig:       tlsConfig,
+	}), nil
+}
+
+// endpoint describes the configured endpoint, i.e. the address, or the sentinels of the master
+func (o *Options) endpoint() string {
+	if o.SentinelMaster == "" {
+		return o.Addr
+	}
+
+	return fmt.Sprintf("master [%s] via sentinels %s", o.SentinelMaster, strings.Join(o.SentinelAddrs, ","))
+}
+
+// masterAddr asks the sentinels for the address of the master, in the configured order
+func (o *Options) masterAddr() (string, error) {
+	tlsConfig, err := o.tlsConfig()
+	if err != nil {
+		return "", err
+	}
+
+	for _, sentinelAddr := range o.SentinelAddrs {
+		sentinel := redis.NewSentinelClient(&redis.Options{
+			Addr:         sentinelAddr,
+			DialTimeout:  o.DialTimeout,
+			ReadTimeout:  o.ReadTimeout,
+			WriteTimeout: o.WriteTimeout,
+			TLSConfig:    tlsConfig,
+		})
+		master, sentinelErr := sentinel.GetMasterAddrByName(o.SentinelMaster).Result()
+		_ = sentinel.Close()
+		if sentinelErr != nil {
+			err = sentinelErr
+			continue
+		}
+		if len(master) != 2 {
+			err = fmt.Errorf("invalid master address %v", master)
+			continue
+		}
+
+		return net.JoinHostPort(master[0], master[1]), nil
+	}
+
+	return "", fmt.Errorf("no sentinel resolved master [%s]: %w", o.SentinelMaster, err)
+}
@@ -0,0 +1,155 @@
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestSentinelRejectsUnsupportedOptions(t *testing.T) {
+	for _, opts := range []Options{
+		{MinIdleConns: 1},
+		{MinRetryBackoff: time.Millisecond},
+		{MaxRetryBackoff: time.Second},
+	} {
+		opts.SentinelMaster = "mymaster"
+		opts.SentinelAddrs = []string{"127.0.0.1:26379"}
+
+		_, err := opts.newClient()
+		if err == nil {
+			t.Fatalf("expected the options %+v to be rejected", opts)
+		}
+	}
+
+	opts := Options{SentinelMaster: "mymaster", SentinelAddrs: []string{"127.0.0.1:26379"}}
+	client, err := opts.newClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = client.Close()
+
+	if endpoint := opts.endpoint(); endpoint != "master [mymaster] via sentinels 127.0.0.1:26379" {
+		t.Fatalf("unexpected endpoint %q", endpoint)
+	}
+}
+
+// fakeSentinel answers the sentinel commands of the failover client, the master is the designated address
+func fakeSentinel(t *testing.T, masterName, masterAddr string) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	host, port, _ := net.SplitHostPort(masterAddr)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSentinel(conn, masterName, host, port)
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+// serveSentinel serves the RESP commands of a single connection
+func serveSentinel(conn net.Conn, masterName, host, port string) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		var reply string
+		switch {
+		case len(args) == 3 && strings.EqualFold(args[0], "sentinel") &&
+			strings.EqualFold(args[1], "get-master-addr-by-name") && args[2] == masterName:
+			reply = fmt.Sprintf("*2\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(host), host, len(port), port)
+		case len(args) > 1 && strings.EqualFold(args[0], "sentinel") && strings.EqualFold(args[1], "sentinels"):
+			reply = "*0\r\n"
+		case len(args) == 2 && strings.HasSuffix(strings.ToLower(args[0]), "subscribe"):
+			reply = fmt.Sprintf("*3\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n:1\r\n", len(args[0]),
+				strings.ToLower(args[0]), len(args[1]), args[1])
+		case len(args) > 0 && strings.EqualFold(args[0], "ping"):
+			reply = "+PONG\r\n"
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+
+		_, err = conn.Write([]byte(reply))
+		if err != nil {
+			return
+		}
+	}
+}
+
+// readCommand reads a RESP array of bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		line, err = r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+
+		p := make([]byte, size+2)
+		_, err = io.ReadFull(r, p)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, string(p[:size]))
+	}
+
+	return args, nil
+}
+
+func TestSentinelReportsResolvedMaster(t *testing.T) {
+	mr := miniredis.RunT(t)
+	sentinelAddr := fakeSentinel(t, "mymaster", mr.Addr())
+
+	r, err := GetRedisWithOptions(&Options{
+		SentinelMaster: "mymaster",
+		SentinelAddrs:  []string{sentinelAddr},
+		DialTimeout:    time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = r.Client.Close() })
+
+	if r.MasterAddr != mr.Addr() {
+		t.Fatalf("expected the master [%s] to be reported, got [%s]", mr.Addr(), r.MasterAddr)
+	}
+	want := fmt.Sprintf("%s (master [mymaster] via sentinels %s)", mr.Addr(), sentinelAddr)
+	if r.Endpoint != want {
+		t.Fatalf("expected the endpoint %q, got %q", want, r.Endpoint)
+	}
+}
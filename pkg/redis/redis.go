@@ -1,6 +1,7 @@
 package redis
 
 import (
+	"fmt"
 	"time"
 
 	"encoding/json"
@@ -9,18 +10,48 @@ import (
 
 type Redis struct {
 	Client *redis.Client
+
+	// Endpoint describes the connected endpoint, e.g. the address, or the master and its sentinels
+	Endpoint string
+
+	// MasterAddr is the master resolved by the sentinels on startup, empty on a single node
+	// the client follows the master on failover afterwards
+	MasterAddr string
 }
 
 // GetRedis build redis object
 func GetRedis(addr, passwd string, db int) (*Redis, error) {
-	redisClient, err := Connect(addr, passwd, db)
+	return GetRedisWithOptions(&Options{Addr: addr, Password: passwd, DB: db})
+}
+
+// GetRedisWithOptions builds redis object with the designated options, e.g. loaded by OptionsFromEnv
+// the connection is verified by a ping on startup, then the master resolved by the sentinels (if any) is reported
+func GetRedisWithOptions(opts *Options) (*Redis, error) {
+	redisClient, err := opts.newClient()
 	if err != nil {
 		return nil, err
 	}
 
+	_, err = redisClient.Ping().Result()
+	if err != nil {
+		_ = redisClient.Close()
+		return nil, fmt.Errorf("failed to ping redis [%s]: %w", opts.endpoint(), err)
+	}
+
 	// builds redis object
 	redisObj := &Redis{
-		Client: redisClient,
+		Client:   redisClient,
+		Endpoint: opts.endpoint(),
+	}
+
+	// the connection is verified already, hence a failure only leaves the master unreported
+	if opts.SentinelMaster != "" {
+		redisObj.MasterAddr, err = opts.masterAddr()
+		if err != nil {
+			redisObj.Endpoint = fmt.Sprintf("%s (the master is not resolved: %s)", redisObj.Endpoint, err.Error())
+		} else {
+			redisObj.Endpoint = fmt.Sprintf("%s (%s)", redisObj.MasterAddr, redisObj.Endpoint)
+		}
 	}
 
 	return redisObj, nil
@@ -59,14 +90,15 @@ func (r *Redis) Set(key string, value interface{}, exp time.Duration) error {
 	return nil
 }
 
-// Get gets value from redis database
+// Get gets value from redis database into destType, which must be a pointer
+// it returns redis.Nil if the key does not exist, see Get[T] for the typed API returning ErrNotFound
 func (r *Redis) Get(key string, destType interface{}) error {
 	val, err := r.Client.Get(key).Result()
 	if err != nil {
 		return err
 	}
 
-	return json.Unmarshal([]byte(val), &destType)
+	return json.Unmarshal([]byte(val), destType)
 }
 
 // GetStr gets value (as a string) from redis database
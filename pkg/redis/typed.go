@@ -0,0 +1,191 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// ErrNotFound is returned if the key does not exist
+var ErrNotFound = errors.New("redis: key not found")
+
+// withContext returns the client bound to the context, or the error of the context if it is already done
+// go-redis v6 does not interrupt a command in flight, so the context is only checked before each command
+func (r *Redis) withContext(ctx context.Context) (*redis.Client, error) {
+	err := ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Client.WithContext(ctx), nil
+}
+
+// notFound maps redis.Nil to ErrNotFound
+func notFound(err error) error {
+	if err == redis.Nil {
+		return ErrNotFound
+	}
+
+	return err
+}
+
+// Get gets the JSON value of the key, returns ErrNotFound if it does not exist
+func Get[T any](ctx context.Context, r *Redis, key string) (T, error) {
+	var value T
+
+	client, err := r.withContext(ctx)
+	if err != nil {
+		return value, err
+	}
+
+	val, err := client.Get(key).Bytes()
+	if err != nil {
+		return value, notFound(err)
+	}
+
+	err = json.Unmarshal(val, &value)
+	if err != nil {
+		return value, fmt.Errorf("failed to decode the value of [%s]: %w", key, err)
+	}
+
+	return value, nil
+}
+
+// GetOrZero gets the JSON value of the key, returns the zero value if it does not exist
+func GetOrZero[T any](ctx context.Context, r *Redis, key string) (T, error) {
+	value, err := Get[T](ctx, r, key)
+	if errors.Is(err, ErrNotFound) {
+		return value, nil
+	}
+
+	return value, err
+}
+
+// Set sets the value of the key as JSON, zero expiration means the key never expires
+func Set[T any](ctx context.Context, r *Redis, key string, value T, exp time.Duration) error {
+	client, err := r.withContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	p, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode the value of [%s]: %w", key, err)
+	}
+
+	return client.Set(key, p, exp).Err()
+}
+
+// MGet gets the JSON values of the keys, mapped by key; the keys which do not exist are omitted
+func MGet[T any](ctx context.Context, r *Redis, keys ...string) (map[string]T, error) {
+	values := make(map[string]T, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+
+	client, err := r.withContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	vals, err := client.MGet(keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, val := range vals {
+		str, ok := val.(string)
+		if !ok {
+			continue
+		}
+
+		var value T
+		err = json.Unmarshal([]byte(str), &value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode the value of [%s]: %w", keys[i], err)
+		}
+		values[keys[i]] = value
+	}
+
+	return values, nil
+}
+
+// Delete deletes the keys, and returns the number of the deleted keys
+func (r *Redis) Delete(ctx context.Context, keys ...string) (int64, error) {
+	client, err := r.withContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return client.Del(keys...).Result()
+}
+
+// Exists checks whether the key exists
+func (r *Redis) Exists(ctx context.Context, key string) (bool, error) {
+	client, err := r.withContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := client.Exists(key).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// Expire sets the expiration of the key, returns ErrNotFound if it does not exist
+func (r *Redis) Expire(ctx context.Context, key string, exp time.Duration) error {
+	client, err := r.withContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	ok, err := client.Expire(key, exp).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// TTL gets the remaining time to live of the key, zero if it never expires; returns ErrNotFound if it does not exist
+func (r *Redis) TTL(ctx context.Context, key string) (time.Duration, error) {
+	client, err := r.withContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	ttl, err := client.TTL(key).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	// redis replies -2 if the key does not exist, and -1 if it has no expiration
+	switch ttl {
+	case -2 * time.Second:
+		return 0, ErrNotFound
+	case -1 * time.Second:
+		return 0, nil
+	}
+
+	return ttl, nil
+}
+
+// Incr increments the integer value of the key by one, a missing key starts from zero
+func (r *Redis) Incr(ctx context.Context, key string) (int64, error) {
+	client, err := r.withContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return client.Incr(key).Result()
+}
@@ -0,0 +1,216 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+)
+
+type testItem struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// newTestRedis connects to an in-process redis stand-in
+func newTestRedis(t *testing.T) (*Redis, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	r, err := GetRedis(mr.Addr(), "", 0)
+	if err != nil {
+		t.Fatalf("failed to connect redis: %v", err)
+	}
+	t.Cleanup(func() { _ = r.Client.Close() })
+
+	return r, mr
+}
+
+func TestGetNotFound(t *testing.T) {
+	r, _ := newTestRedis(t)
+	ctx := context.Background()
+
+	_, err := Get[testItem](ctx, r, "missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	item, err := GetOrZero[testItem](ctx, r, "missing")
+	if err != nil || item != (testItem{}) {
+		t.Fatalf("expected the zero value, got %+v, %v", item, err)
+	}
+}
+
+func TestSetGetRoundTrip(t *testing.T) {
+	r, mr := newTestRedis(t)
+	ctx := context.Background()
+
+	err := Set(ctx, r, "item", testItem{Id: 1, Name: "one"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := Get[testItem](ctx, r, "item")
+	if err != nil || item != (testItem{Id: 1, Name: "one"}) {
+		t.Fatalf("unexpected item %+v, %v", item, err)
+	}
+
+	ptr, err := Get[*testItem](ctx, r, "item")
+	if err != nil || ptr == nil || ptr.Id != 1 {
+		t.Fatalf("unexpected item pointer %+v, %v", ptr, err)
+	}
+
+	if ttl := mr.TTL("item"); ttl != time.Minute {
+		t.Fatalf("expected the expiration of 1m, got %s", ttl)
+	}
+
+	// a value which is not JSON is reported, not silently zeroed
+	mr.Set("raw", "not json")
+	_, err = Get[testItem](ctx, r, "raw")
+	if err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected a decoding error, got %v", err)
+	}
+}
+
+func TestRedisGetRoundTrip(t *testing.T) {
+	r, _ := newTestRedis(t)
+
+	err := r.Set("item", testItem{Id: 2, Name: "two"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var item testItem
+	err = r.Get("item", &item)
+	if err != nil || item != (testItem{Id: 2, Name: "two"}) {
+		t.Fatalf("unexpected item %+v, %v", item, err)
+	}
+
+	err = r.Get("missing", &item)
+	if err != redis.Nil {
+		t.Fatalf("expected redis.Nil, got %v", err)
+	}
+}
+
+func TestMGet(t *testing.T) {
+	r, _ := newTestRedis(t)
+	ctx := context.Background()
+
+	for _, item := range []testItem{{Id: 1}, {Id: 3}} {
+		err := Set(ctx, r, key(item.Id), item, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	items, err := MGet[testItem](ctx, r, key(1), key(2), key(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[key(1)].Id != 1 || items[key(3)].Id != 3 {
+		t.Fatalf("unexpected items %+v", items)
+	}
+	if _, ok := items[key(2)]; ok {
+		t.Fatal("the missing key must be omitted")
+	}
+
+	items, err = MGet[testItem](ctx, r)
+	if err != nil || len(items) != 0 {
+		t.Fatalf("expected no item, got %+v, %v", items, err)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	r, mr := newTestRedis(t)
+	ctx := context.Background()
+
+	mr.Set("persistent", "1")
+	mr.Set("volatile", "1")
+	mr.SetTTL("volatile", 30*time.Second)
+
+	_, err := r.TTL(ctx, "missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound on -2, got %v", err)
+	}
+
+	ttl, err := r.TTL(ctx, "persistent")
+	if err != nil || ttl != 0 {
+		t.Fatalf("expected zero on -1, got %s, %v", ttl, err)
+	}
+
+	ttl, err = r.TTL(ctx, "volatile")
+	if err != nil || ttl != 30*time.Second {
+		t.Fatalf("expected 30s, got %s, %v", ttl, err)
+	}
+
+	err = r.Expire(ctx, "persistent", time.Minute)
+	if err != nil || mr.TTL("persistent") != time.Minute {
+		t.Fatalf("failed to expire: %v", err)
+	}
+	err = r.Expire(ctx, "missing", time.Minute)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestKeyOperations(t *testing.T) {
+	r, _ := newTestRedis(t)
+	ctx := context.Background()
+
+	for i := int64(1); i <= 2; i++ {
+		n, err := r.Incr(ctx, "counter")
+		if err != nil || n != i {
+			t.Fatalf("expected %d, got %d, %v", i, n, err)
+		}
+	}
+
+	ok, err := r.Exists(ctx, "counter")
+	if err != nil || !ok {
+		t.Fatalf("expected the counter to exist, got %v", err)
+	}
+
+	n, err := r.Delete(ctx, "counter", "missing")
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 deleted key, got %d, %v", n, err)
+	}
+
+	ok, err = r.Exists(ctx, "counter")
+	if err != nil || ok {
+		t.Fatalf("expected the counter to be deleted, got %v", err)
+	}
+}
+
+func TestCancelledContext(t *testing.T) {
+	r, mr := newTestRedis(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := Set(ctx, r, "item", testItem{Id: 1}, 0)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if mr.Exists("item") {
+		t.Fatal("the command must not be issued")
+	}
+
+	_, err = Get[testItem](ctx, r, "item")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	_, err = MGet[testItem](ctx, r, "item")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	_, err = r.Incr(ctx, "counter")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+// key builds the test key of the item
+func key(id int) string {
+	return "item:" + string(rune('0'+id))
+}
@@ -0,0 +1,112 @@
+package schedule
+
+import (
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// DefaultReplyTemplate defines the default out-of-hours auto-reply
+const DefaultReplyTemplate = "Hi {{.Name}}, thank you for your message. We are currently closed" +
+	"{{if .Holiday}} for {{.Holiday}}{{end}}, and will get back to you once we open" +
+	"{{if .NextOpening}} on {{.NextOpening}}{{end}}."
+
+// ReplyData defines the data of the auto-reply template
+type ReplyData struct {
+	Name        string // the contact name, may be empty
+	Holiday     string // the holiday name, empty if it is not a holiday
+	NextOpening string // the formatted next opening, empty if the schedule never opens
+}
+
+// AutoReplier builds the auto-replies of the messages received out of hours,
+// at most once per conversation per closed window
+type AutoReplier struct {
+	Schedule *Schedule
+	Template *template.Template
+
+	mu      sync.Mutex
+	replied map[string]time.Time // the end of the replied window (i.e. the next opening), mapped by conversation
+}
+
+// NewAutoReplier builds the auto-replier, an empty template uses DefaultReplyTemplate
+func NewAutoReplier(s *Schedule, tmpl string) (*AutoReplier, error) {
+	if tmpl == "" {
+		tmpl = DefaultReplyTemplate
+	}
+
+	t, err := template.New("autoreply").Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AutoReplier{
+		Schedule: s,
+		Template: t,
+		replied:  make(map[string]time.Time),
+	}, nil
+}
+
+// Reply builds the auto-reply of the message received at the designated time, and sends it with the send function
+// it returns false if the schedule is open, or if the conversation has been replied in the current window
+// the window is marked as replied only once the reply is sent, so that a failed reply is retried on the next message
+func (a *AutoReplier) Reply(conversation, name string, ts time.Time, send func(reply string) error) (bool, error) {
+	if a.Schedule.IsOpen(ts) {
+		return false, nil
+	}
+
+	// the closed window is identified by its end, a schedule which never opens has a single window
+	windowEnd, opens := a.Schedule.NextOpening(ts)
+
+	a.mu.Lock()
+	a.cleanup(ts)
+	if end, ok := a.replied[conversation]; ok && end.Equal(windowEnd) {
+		a.mu.Unlock()
+		return false, nil
+	}
+	// reserves the window, so that concurrent messages are not replied twice
+	a.replied[conversation] = windowEnd
+	a.mu.Unlock()
+
+	reply, err := a.render(name, ts, windowEnd, opens)
+	if err == nil {
+		err = send(reply)
+	}
+	if err != nil {
+		a.mu.Lock()
+		if end, ok := a.replied[conversation]; ok && end.Equal(windowEnd) {
+			delete(a.replied, conversation)
+		}
+		a.mu.Unlock()
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// render renders the auto-reply of the closed window
+func (a *AutoReplier) render(name string, ts, windowEnd time.Time, opens bool) (string, error) {
+	data := ReplyData{Name: name}
+	data.Holiday, _ = a.Schedule.Holiday(ts)
+	if opens {
+		data.NextOpening = a.Schedule.Format(windowEnd)
+	}
+
+	var sb strings.Builder
+	err := a.Template.Execute(&sb, data)
+	if err != nil {
+		return "", err
+	}
+
+	return sb.String(), nil
+}
+
+// cleanup removes the windows which have ended
+func (a *AutoReplier) cleanup(now time.Time) {
+	for conversation, end := range a.replied {
+		if !end.IsZero() && !end.After(now) {
+			delete(a.replied, conversation)
+		}
+	}
+}
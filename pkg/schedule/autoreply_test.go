@@ -0,0 +1,55 @@
+package schedule
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAutoReplierRetriesFailedReply(t *testing.T) {
+	s, err := New("UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// closed on every day, except mondays
+	if err = s.SetHours(time.Monday, "09:00-17:00"); err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewAutoReplier(s, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sunday := time.Date(2023, 5, 7, 10, 0, 0, 0, time.UTC)
+	var sent []string
+	send := func(reply string) error {
+		sent = append(sent, reply)
+		return nil
+	}
+
+	failure := errors.New("not connected")
+	ok, err := a.Reply("chat", "John", sunday, func(reply string) error { return failure })
+	if ok || !errors.Is(err, failure) {
+		t.Fatalf("expected the failed reply to be reported, got %v, %v", ok, err)
+	}
+
+	// the failed reply does not mark the window as replied
+	ok, err = a.Reply("chat", "John", sunday.Add(time.Minute), send)
+	if !ok || err != nil || len(sent) != 1 || !strings.HasPrefix(sent[0], "Hi John") {
+		t.Fatalf("expected the reply to be sent, got %v, %v, %q", ok, err, sent)
+	}
+
+	// once per window
+	ok, err = a.Reply("chat", "John", sunday.Add(2*time.Minute), send)
+	if ok || err != nil || len(sent) != 1 {
+		t.Fatalf("expected a single reply in the window, got %v, %v, %q", ok, err, sent)
+	}
+
+	// the schedule is open
+	monday := time.Date(2023, 5, 8, 10, 0, 0, 0, time.UTC)
+	ok, err = a.Reply("chat", "John", monday, send)
+	if ok || err != nil || len(sent) != 1 {
+		t.Fatalf("expected no reply while open, got %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,183 @@
+// Package schedule provides the business hours, e.g. to auto-reply the messages received out of hours
+package schedule
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ardihikaru/go-modules/pkg/utils/common"
+)
+
+// maxSearchDays bounds the search of the next opening, e.g. if every day is closed
+const maxSearchDays = 400
+
+// TimeRange defines an opening period of a day, as minutes since midnight
+type TimeRange struct {
+	Open  int
+	Close int
+}
+
+// ParseRange parses an opening period formatted as "HH:MM-HH:MM", e.g. "09:00-17:00"
+// "24:00" closes at midnight; a period spanning midnight must be split into two days
+func ParseRange(s string) (TimeRange, error) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) != 2 {
+		return TimeRange{}, fmt.Errorf("invalid time range [%s], HH:MM-HH:MM is expected", s)
+	}
+
+	open, err := parseClock(parts[0])
+	if err != nil {
+		return TimeRange{}, err
+	}
+	closing, err := parseClock(parts[1])
+	if err != nil {
+		return TimeRange{}, err
+	}
+	if closing <= open {
+		return TimeRange{}, fmt.Errorf("invalid time range [%s], it must close after it opens", s)
+	}
+
+	return TimeRange{Open: open, Close: closing}, nil
+}
+
+// parseClock parses "HH:MM" as minutes since midnight
+func parseClock(s string) (int, error) {
+	hm := strings.Split(strings.TrimSpace(s), ":")
+	if len(hm) != 2 {
+		return 0, fmt.Errorf("invalid time [%s], HH:MM is expected", s)
+	}
+
+	hour, err := strconv.Atoi(hm[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid hour of time [%s]", s)
+	}
+	minute, err := strconv.Atoi(hm[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid minute of time [%s]", s)
+	}
+
+	clock := hour*60 + minute
+	if hour < 0 || minute < 0 || minute > 59 || clock > 24*60 {
+		return 0, fmt.Errorf("invalid time [%s]", s)
+	}
+
+	return clock, nil
+}
+
+// Schedule defines the weekly opening hours in a timezone, and the holidays which are closed all day
+type Schedule struct {
+	// Location is the timezone of the opening hours and the holidays
+	Location *time.Location
+
+	// TimezoneLabel is appended to the formatted times, e.g. "WIB"; empty uses the zone abbreviation
+	TimezoneLabel string
+
+	hours    map[time.Weekday][]TimeRange
+	holidays map[string]string // the holiday names, mapped by date (see common.ToDateString)
+}
+
+// New builds the schedule of the designated IANA timezone (e.g. "Asia/Jakarta"), closed on every day
+func New(timezone string) (*Schedule, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Schedule{
+		Location: loc,
+		hours:    make(map[time.Weekday][]TimeRange),
+		holidays: make(map[string]string),
+	}, nil
+}
+
+// SetHours sets the opening periods of the weekday, e.g. "08:00-12:00", "13:00-17:00"; none closes the day
+func (s *Schedule) SetHours(day time.Weekday, ranges ...string) error {
+	periods := make([]TimeRange, 0, len(ranges))
+	for _, r := range ranges {
+		period, err := ParseRange(r)
+		if err != nil {
+			return err
+		}
+		periods = append(periods, period)
+	}
+
+	sort.Slice(periods, func(i, j int) bool {
+		return periods[i].Open < periods[j].Open
+	})
+	s.hours[day] = periods
+
+	return nil
+}
+
+// AddHoliday closes the designated date ("2006-01-02") all day
+func (s *Schedule) AddHoliday(date, name string) error {
+	d, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return fmt.Errorf("invalid holiday date [%s]: %w", date, err)
+	}
+	s.holidays[common.ToDateString(d)] = name
+
+	return nil
+}
+
+// Holiday returns the holiday name of the designated time, if it is a holiday
+func (s *Schedule) Holiday(t time.Time) (string, bool) {
+	name, ok := s.holidays[common.ToDateString(t.In(s.Location))]
+
+	return name, ok
+}
+
+// IsOpen checks whether the designated time is within the opening hours
+func (s *Schedule) IsOpen(t time.Time) bool {
+	if _, ok := s.Holiday(t); ok {
+		return false
+	}
+
+	lt := t.In(s.Location)
+	clock := lt.Hour()*60 + lt.Minute()
+	for _, period := range s.hours[lt.Weekday()] {
+		if clock >= period.Open && clock < period.Close {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NextOpening returns the start of the next opening period after the designated time,
+// false if the schedule never opens
+func (s *Schedule) NextOpening(t time.Time) (time.Time, bool) {
+	lt := t.In(s.Location)
+	year, month, day := lt.Date()
+
+	for offset := 0; offset < maxSearchDays; offset++ {
+		date := time.Date(year, month, day+offset, 0, 0, 0, 0, s.Location)
+		if _, ok := s.Holiday(date); ok {
+			continue
+		}
+
+		for _, period := range s.hours[date.Weekday()] {
+			opening := time.Date(year, month, day+offset, period.Open/60, period.Open%60, 0, 0, s.Location)
+			if opening.After(t) {
+				return opening, true
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// Format formats the time in the schedule timezone, e.g. "2023-05-01 09:00 WIB"
+func (s *Schedule) Format(t time.Time) string {
+	lt := t.In(s.Location)
+
+	tz := s.TimezoneLabel
+	if tz == "" {
+		tz, _ = lt.Zone()
+	}
+
+	return fmt.Sprintf("%s %s", common.ToDateString(lt), common.ToTimeStringWithTz(lt, tz))
+}
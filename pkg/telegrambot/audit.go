@@ -0,0 +1,273 @@
+package telegrambot
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	tgBotApi "github.com/matterbridge/telegram-bot-api/v6"
+
+	"github.com/ardihikaru/go-modules/pkg/utils/httputils"
+)
+
+// results of the bridged replies
+const (
+	AuditSent   = "sent"
+	AuditFailed = "failed"
+	AuditDenied = "denied"
+)
+
+// defaultAuditLimit defines the default number of audit entries returned by a query
+const defaultAuditLimit = 100
+
+// AgentPolicy defines which telegram users are allowed to reply to the whatsapp contacts
+type AgentPolicy struct {
+	// AllowedUserIds lists the allowed telegram user IDs, an empty list allows every member
+	AllowedUserIds []int64
+
+	// AdminsOnly only allows the administrators (and the creator) of the chat
+	// if AllowedUserIds is not empty, the listed users are allowed as well
+	AdminsOnly bool
+}
+
+// AuditEntry records a reply bridged from telegram to whatsapp
+type AuditEntry struct {
+	TgChatId    int64     `json:"tg_chat_id"`
+	TgMsgId     int       `json:"tg_msg_id"`
+	TgUserId    int64     `json:"tg_user_id"`
+	TgUsername  string    `json:"tg_username"`
+	Phone       string    `json:"phone"`
+	Message     string    `json:"message"`
+	Result      string    `json:"result"` // sent, failed, or denied
+	Error       string    `json:"error,omitempty"`
+	ReceivedAt  time.Time `json:"received_at"`  // when the agent sent the telegram message
+	CompletedAt time.Time `json:"completed_at"` // when the bridge completed (or rejected) the reply
+}
+
+// AuditFilter defines the criteria of the audit query, the zero values are ignored
+type AuditFilter struct {
+	TgUserId int64
+	Phone    string
+	Result   string
+	From     time.Time // inclusive, based on ReceivedAt
+	To       time.Time // exclusive, based on ReceivedAt
+	Limit    int       // zero uses the default limit
+	Offset   int
+}
+
+// AuditStore defines the storage of the audit log
+type AuditStore interface {
+	// SaveAudit stores the audit entry
+	SaveAudit(e *AuditEntry) error
+
+	// QueryAudit gets the audit entries matching the filter, the latest entry first
+	QueryAudit(f AuditFilter) ([]*AuditEntry, error)
+}
+
+// match checks whether the audit entry matches the filter
+func (f *AuditFilter) match(e *AuditEntry) bool {
+	if f.TgUserId != 0 && e.TgUserId != f.TgUserId {
+		return false
+	}
+	if f.Phone != "" && e.Phone != f.Phone {
+		return false
+	}
+	if f.Result != "" && e.Result != f.Result {
+		return false
+	}
+	if !f.From.IsZero() && e.ReceivedAt.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !e.ReceivedAt.Before(f.To) {
+		return false
+	}
+
+	return true
+}
+
+// limit returns the number of audit entries to return
+func (f *AuditFilter) limit() int {
+	if f.Limit <= 0 {
+		return defaultAuditLimit
+	}
+
+	return f.Limit
+}
+
+// WriteAuditCSV exports the audit entries as CSV, e.g. for the compliance reviews
+func WriteAuditCSV(w io.Writer, entries []*AuditEntry) error {
+	cw := csv.NewWriter(w)
+
+	err := cw.Write([]string{"received_at", "completed_at", "tg_chat_id", "tg_msg_id", "tg_user_id", "tg_username",
+		"phone", "message", "result", "error"})
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		err = cw.Write([]string{
+			e.ReceivedAt.UTC().Format(time.RFC3339),
+			e.CompletedAt.UTC().Format(time.RFC3339),
+			strconv.FormatInt(e.TgChatId, 10),
+			strconv.Itoa(e.TgMsgId),
+			strconv.FormatInt(e.TgUserId, 10),
+			e.TgUsername,
+			e.Phone,
+			e.Message,
+			e.Result,
+			e.Error,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// AuditHandler builds the HTTP handler to query (and export) the audit log, e.g. mounted on "/audit"
+//
+// Query parameters: user_id, phone, result, from and to (RFC3339), limit, offset, and format ("json" or "csv")
+func AuditHandler(store AuditStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, appErrCode, err := auditFilterFromQuery(r.URL.Query())
+		if err != nil {
+			httputils.RenderErrResponse(w, r, httputils.ResponseText("", appErrCode), int64(appErrCode),
+				http.StatusBadRequest, err)
+			return
+		}
+
+		entries, err := store.QueryAudit(f)
+		if err != nil {
+			httputils.RenderErrResponse(w, r, httputils.ResponseText("", httputils.FailedToFetchData),
+				httputils.FailedToFetchData, http.StatusInternalServerError, err)
+			return
+		}
+
+		if r.URL.Query().Get("format") == "csv" {
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", `attachment; filename="audit.csv"`)
+			err = WriteAuditCSV(w, entries)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		_ = httputils.RenderOKResponse(w, r, httputils.Response{
+			Data: entries,
+		})
+	})
+}
+
+// auditFilterFromQuery extracts the audit filter from the URL query
+func auditFilterFromQuery(q url.Values) (AuditFilter, int, error) {
+	var err error
+	f := AuditFilter{
+		Phone:  q.Get("phone"),
+		Result: q.Get("result"),
+	}
+
+	if v := q.Get("user_id"); v != "" {
+		f.TgUserId, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return f, httputils.InvalidURLParameters, fmt.Errorf("invalid user_id [%s]", v)
+		}
+	}
+	if v := q.Get("from"); v != "" {
+		f.From, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			return f, httputils.InvalidURLParameters, fmt.Errorf("invalid from [%s], RFC3339 is expected", v)
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		f.To, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			return f, httputils.InvalidURLParameters, fmt.Errorf("invalid to [%s], RFC3339 is expected", v)
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		f.Limit, err = strconv.Atoi(v)
+		if err != nil || f.Limit < 0 {
+			return f, httputils.InvalidLimitValue, fmt.Errorf("invalid limit [%s]", v)
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		f.Offset, err = strconv.Atoi(v)
+		if err != nil || f.Offset < 0 {
+			return f, httputils.InvalidOffsetValue, fmt.Errorf("invalid offset [%s]", v)
+		}
+	}
+
+	return f, 0, nil
+}
+
+// authorizeAgent checks whether the sender of the telegram message is allowed to reply to the whatsapp contacts
+func (b *TelegramBot) authorizeAgent(message *tgBotApi.Message) bool {
+	if b.Agents == nil {
+		return true
+	}
+	if message.From == nil {
+		return false
+	}
+
+	for _, userId := range b.Agents.AllowedUserIds {
+		if userId == message.From.ID {
+			return true
+		}
+	}
+
+	if b.Agents.AdminsOnly {
+		member, err := b.Bot.GetChatMember(tgBotApi.GetChatMemberConfig{
+			ChatConfigWithUser: tgBotApi.ChatConfigWithUser{ChatID: message.Chat.ID, UserID: message.From.ID},
+		})
+		if err != nil {
+			b.log.Warn(fmt.Sprintf("failed to get the chat member [%d] -> %s", message.From.ID, err.Error()))
+			return false
+		}
+
+		return member.IsAdministrator() || member.IsCreator()
+	}
+
+	// with an empty allowlist, every member is allowed
+	return len(b.Agents.AllowedUserIds) == 0
+}
+
+// audit records the bridged reply of the telegram message, if the audit store exists
+func (b *TelegramBot) audit(message *tgBotApi.Message, phone, text, result string, cause error) {
+	entry := &AuditEntry{
+		TgChatId:   message.Chat.ID,
+		TgMsgId:    message.MessageID,
+		Phone:      phone,
+		Message:    text,
+		Result:     result,
+		ReceivedAt: message.Time().UTC(),
+	}
+	if message.From != nil {
+		entry.TgUserId = message.From.ID
+		entry.TgUsername = message.From.UserName
+	}
+	if cause != nil {
+		entry.Error = cause.Error()
+	}
+
+	b.saveAudit(entry)
+}
+
+// saveAudit stores the audit entry completed now, if the audit store exists
+func (b *TelegramBot) saveAudit(entry *AuditEntry) {
+	if b.Audit == nil {
+		return
+	}
+	entry.CompletedAt = time.Now().UTC()
+
+	err := b.Audit.SaveAudit(entry)
+	if err != nil {
+		b.log.Warn(fmt.Sprintf("failed to store the audit entry -> %s", err.Error()))
+	}
+}
@@ -0,0 +1,90 @@
+package telegrambot
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	goRedis "github.com/go-redis/redis"
+
+	"github.com/ardihikaru/go-modules/pkg/redis"
+)
+
+// auditKey is the redis key of the sorted set of the audit entries
+const auditKey = "tgbot:audit"
+
+// auditPageSize defines the number of audit entries read from redis at once by QueryAudit
+const auditPageSize = 500
+
+// RedisAuditStore stores the audit log in redis, as a sorted set scored by the time the agent sent the message
+type RedisAuditStore struct {
+	rdb *redis.Redis
+}
+
+// NewRedisAuditStore builds the redis audit store
+func NewRedisAuditStore(rdb *redis.Redis) *RedisAuditStore {
+	return &RedisAuditStore{rdb: rdb}
+}
+
+// SaveAudit stores the audit entry, sorted by the time the agent sent the message
+// audit entries never expire, since they are kept for the compliance reviews
+func (s *RedisAuditStore) SaveAudit(e *AuditEntry) error {
+	p, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	return s.rdb.Client.ZAdd(auditKey, goRedis.Z{
+		Score:  float64(e.ReceivedAt.UnixNano()),
+		Member: p,
+	}).Err()
+}
+
+// QueryAudit gets the audit entries matching the filter, the latest entry first
+func (s *RedisAuditStore) QueryAudit(f AuditFilter) ([]*AuditEntry, error) {
+	opt := goRedis.ZRangeBy{Min: "-inf", Max: "+inf"}
+	if !f.From.IsZero() {
+		opt.Min = strconv.FormatInt(f.From.UnixNano(), 10)
+	}
+	if !f.To.IsZero() {
+		opt.Max = fmt.Sprintf("(%d", f.To.UnixNano())
+	}
+
+	// the remaining criteria are filtered in memory, the entries are read page by page until the limit is filled
+	opt.Count = auditPageSize
+	entries := make([]*AuditEntry, 0)
+	skipped := 0
+	for {
+		vals, err := s.rdb.Client.ZRevRangeByScore(auditKey, opt).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, val := range vals {
+			var e AuditEntry
+			err = json.Unmarshal([]byte(val), &e)
+			if err != nil {
+				return nil, err
+			}
+			if !f.match(&e) {
+				continue
+			}
+			if skipped < f.Offset {
+				skipped++
+				continue
+			}
+
+			entries = append(entries, &e)
+			if len(entries) == f.limit() {
+				return entries, nil
+			}
+		}
+
+		if int64(len(vals)) < opt.Count {
+			break
+		}
+		opt.Offset += opt.Count
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,65 @@
+package telegrambot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/ardihikaru/go-modules/pkg/redis"
+)
+
+func TestRedisAuditStoreQueryPages(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb, err := redis.GetRedis(mr.Addr(), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = rdb.Client.Close() })
+	store := NewRedisAuditStore(rdb)
+
+	// the entries of the phone are spread over several pages, behind the entries of the other phones
+	start := time.Date(2023, 5, 1, 9, 0, 0, 0, time.UTC)
+	total := 3*auditPageSize + 10
+	for i := 0; i < total; i++ {
+		phone := "62811"
+		if i%100 == 0 {
+			phone = "62812"
+		}
+
+		err = store.SaveAudit(&AuditEntry{
+			TgMsgId:    i,
+			Phone:      phone,
+			Message:    fmt.Sprint(i),
+			Result:     AuditSent,
+			ReceivedAt: start.Add(time.Duration(i) * time.Second),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := store.QueryAudit(AuditFilter{Phone: "62812", Offset: 1, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 10 || entries[0].TgMsgId != 1400 || entries[9].TgMsgId != 500 {
+		t.Fatalf("expected the entries 1400 to 500, got %d entries", len(entries))
+	}
+
+	entries, err = store.QueryAudit(AuditFilter{Phone: "62812", Offset: 10})
+	if err != nil || len(entries) != 6 || entries[5].TgMsgId != 0 {
+		t.Fatalf("expected the remaining 6 entries, got %d, %v", len(entries), err)
+	}
+
+	entries, err = store.QueryAudit(AuditFilter{Phone: "62811", To: start.Add(time.Second)})
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("expected no entry, got %d, %v", len(entries), err)
+	}
+
+	entries, err = store.QueryAudit(AuditFilter{})
+	if err != nil || len(entries) != defaultAuditLimit || entries[0].TgMsgId != total-1 {
+		t.Fatalf("expected the latest %d entries, got %d, %v", defaultAuditLimit, len(entries), err)
+	}
+}
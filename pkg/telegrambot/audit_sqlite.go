@@ -0,0 +1,99 @@
+package telegrambot
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// SQLiteAuditStore stores the audit log in a SQLite database
+type SQLiteAuditStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteAuditStore builds the SQLite audit store and prepares the table
+func NewSQLiteAuditStore(dbName string) (*SQLiteAuditStore, error) {
+	db, err := openSQLite(dbName,
+		`CREATE TABLE IF NOT EXISTS tg_audit (
+		id           INTEGER PRIMARY KEY AUTOINCREMENT,
+		tg_chat_id   INTEGER NOT NULL,
+		tg_msg_id    INTEGER NOT NULL,
+		tg_user_id   INTEGER NOT NULL,
+		tg_username  TEXT NOT NULL,
+		phone        TEXT NOT NULL,
+		message      TEXT NOT NULL,
+		result       TEXT NOT NULL,
+		error        TEXT NOT NULL,
+		received_at  TIMESTAMP NOT NULL,
+		completed_at TIMESTAMP NOT NULL
+	)`,
+		`CREATE INDEX IF NOT EXISTS tg_audit_received_at ON tg_audit (received_at)`)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQLiteAuditStore{db: db}, nil
+}
+
+// SaveAudit stores the audit entry
+func (s *SQLiteAuditStore) SaveAudit(e *AuditEntry) error {
+	_, err := s.db.Exec(`INSERT INTO tg_audit
+		(tg_chat_id, tg_msg_id, tg_user_id, tg_username, phone, message, result, error, received_at, completed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.TgChatId, e.TgMsgId, e.TgUserId, e.TgUsername, e.Phone, e.Message, e.Result, e.Error, e.ReceivedAt,
+		e.CompletedAt)
+
+	return err
+}
+
+// QueryAudit gets the audit entries matching the filter, the latest entry first
+func (s *SQLiteAuditStore) QueryAudit(f AuditFilter) ([]*AuditEntry, error) {
+	where := []string{"1 = 1"}
+	var args []interface{}
+	if f.TgUserId != 0 {
+		where = append(where, "tg_user_id = ?")
+		args = append(args, f.TgUserId)
+	}
+	if f.Phone != "" {
+		where = append(where, "phone = ?")
+		args = append(args, f.Phone)
+	}
+	if f.Result != "" {
+		where = append(where, "result = ?")
+		args = append(args, f.Result)
+	}
+	if !f.From.IsZero() {
+		where = append(where, "received_at >= ?")
+		args = append(args, f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		where = append(where, "received_at < ?")
+		args = append(args, f.To.UTC())
+	}
+	args = append(args, f.limit(), f.Offset)
+
+	rows, err := s.db.Query(`SELECT tg_chat_id, tg_msg_id, tg_user_id, tg_username, phone, message, result, error,
+		received_at, completed_at FROM tg_audit WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY received_at DESC, id DESC LIMIT ? OFFSET ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]*AuditEntry, 0)
+	for rows.Next() {
+		var e AuditEntry
+		err = rows.Scan(&e.TgChatId, &e.TgMsgId, &e.TgUserId, &e.TgUsername, &e.Phone, &e.Message, &e.Result,
+			&e.Error, &e.ReceivedAt, &e.CompletedAt)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &e)
+	}
+
+	return entries, rows.Err()
+}
+
+// Close closes the database connection
+func (s *SQLiteAuditStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,336 @@
+package telegrambot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	tgBotApi "github.com/matterbridge/telegram-bot-api/v6"
+	"go.mau.fi/whatsmeow/types"
+
+	m "github.com/ardihikaru/go-modules/pkg/messenger"
+	ph "github.com/ardihikaru/go-modules/pkg/utils/phone"
+)
+
+// agent commands
+const (
+	cmdSend    = "send"
+	cmdStatus  = "status"
+	cmdHistory = "history"
+	cmdWhois   = "whois"
+	cmdClaim   = "claim"
+	cmdRelease = "release"
+	cmdClose   = "close"
+)
+
+const (
+	defaultHistorySize = 10
+	maxHistoryLimit    = 50
+)
+
+// noMessengerReply is the reply of the commands which require the messenger, if it is not configured
+const noMessengerReply = "messenger is not configured"
+
+// botCommands lists the agent commands registered on the configured group
+var botCommands = []tgBotApi.BotCommand{
+	{Command: cmdSend, Description: "Start a new conversation: /send <phone> <text>"},
+	{Command: cmdStatus, Description: "Show the WhatsApp session and gateway health"},
+	{Command: cmdHistory, Description: "Show recent messages: /history <phone> [n]"},
+	{Command: cmdWhois, Description: "Show the contact info: /whois <phone>"},
+	{Command: cmdClaim, Description: "Assign the conversation to yourself: /claim [phone]"},
+	{Command: cmdRelease, Description: "Unassign your conversation: /release [phone]"},
+	{Command: cmdClose, Description: "Resolve the conversation: /close [phone]"},
+}
+
+// RegisterCommands registers the agent commands (setMyCommands), only visible on the configured group
+// and the target chats of the routing rules
+func (b *TelegramBot) RegisterCommands() error {
+	for _, chatId := range b.routedChats() {
+		_, err := b.Bot.Request(tgBotApi.NewSetMyCommandsWithScope(
+			tgBotApi.NewBotCommandScopeChat(chatId), botCommands...))
+		if err != nil {
+			return fmt.Errorf("failed to register the commands of chat [%d]: %w", chatId, err)
+		}
+	}
+
+	return nil
+}
+
+// initCommands registers the agent commands on the configured chats, if any
+func (b *TelegramBot) initCommands() {
+	if len(b.routedChats()) == 0 {
+		return
+	}
+
+	err := b.RegisterCommands()
+	if err != nil {
+		b.log.Warn(fmt.Sprintf("failed to register the bot commands -> %s", err.Error()))
+	}
+}
+
+// isConfiguredGroup checks whether the chat is the configured group
+func (b *TelegramBot) isConfiguredGroup(chat *tgBotApi.Chat) bool {
+	if chat == nil {
+		return false
+	}
+
+	return chat.ID == b.groupChatId || (b.groupTitle != "" && chat.Title == b.groupTitle)
+}
+
+// handleCommand handles the agent commands, it returns false if the message is not a known command
+func (b *TelegramBot) handleCommand(message *tgBotApi.Message) bool {
+	if !message.IsCommand() || !isAgentCommand(message.Command()) {
+		return false
+	}
+
+	// commands are restricted to the configured group and the target chats of the routing rules
+	if !b.isRoutedChat(message.Chat) {
+		b.log.Debug(fmt.Sprintf("ignores command [%s] from an unknown chat [%d]", message.Command(), message.Chat.ID))
+		return true
+	}
+
+	var reply string
+	switch message.Command() {
+	case cmdSend:
+		reply = b.cmdSend(message)
+	case cmdStatus:
+		reply = b.cmdStatus()
+	case cmdHistory:
+		reply = b.cmdHistory(message)
+	case cmdWhois:
+		reply = b.cmdWhois(message.CommandArguments())
+	case cmdClaim:
+		reply = b.cmdClaim(message)
+	case cmdRelease:
+		reply = b.cmdRelease(message)
+	case cmdClose:
+		reply = b.cmdClose(message)
+	}
+
+	if reply != "" {
+		_, err := b.replyTo(message, reply)
+		if err != nil {
+			b.log.Warn(fmt.Sprintf("failed to reply the [%s] command -> %s", message.Command(), err.Error()))
+		}
+	}
+
+	return true
+}
+
+// isAgentCommand checks whether the command is one of the agent commands
+func isAgentCommand(command string) bool {
+	for _, c := range botCommands {
+		if c.Command == command {
+			return true
+		}
+	}
+
+	return false
+}
+
+// cmdSend starts a new conversation: /send <phone> <text>
+func (b *TelegramBot) cmdSend(message *tgBotApi.Message) string {
+	// the phone is the first word, the rest is sent verbatim (e.g. with its line breaks)
+	arguments := strings.TrimLeftFunc(message.CommandArguments(), unicode.IsSpace)
+	args := strings.Fields(arguments)
+	if len(args) < 2 {
+		return "usage: /send <phone> <text>"
+	}
+	if b.Messenger == nil {
+		return noMessengerReply
+	}
+
+	phone, err := ph.ToJIDUser(args[0], ph.DefaultRegion)
+	if err != nil {
+		return fmt.Sprintf("invalid phone [%s]: %s", args[0], err.Error())
+	}
+	text := strings.TrimLeftFunc(strings.TrimPrefix(arguments, args[0]), unicode.IsSpace)
+
+	// only the authorized agents are allowed to start a conversation
+	if !b.authorizeAgent(message) {
+		b.audit(message, phone, text, AuditDenied, nil)
+		return "you are not allowed to send messages to the WhatsApp contacts"
+	}
+
+	// only the ticket assignee is expected to reply
+	if !b.checkAssignee(message, phone) {
+		b.audit(message, phone, text, AuditDenied, nil)
+		return ""
+	}
+
+	waMsg := &WhatsappMessage{
+		ChatJID: types.NewJID(phone, types.DefaultUserServer).String(),
+		Phone:   phone,
+	}
+
+	// the outbox worker delivers the message, if enabled
+	if b.Outbox != nil {
+		status, ok := b.enqueueReply(message, phone, text, nil)
+		if ok && status.MessageID != 0 {
+			// replies to the status message continue the conversation
+			b.saveCorrelation(status, waMsg)
+		}
+		return ""
+	}
+
+	result, err := b.Messenger.SendToWhatsapp(context.Background(), m.PostWhatsappMsg{Phone: ph.FromJIDUser(phone),
+		Message: text})
+	if err != nil {
+		b.log.Warn(fmt.Sprintf("failed to send a new message to [%s] -> %s", phone, err.Error()))
+		b.audit(message, phone, text, AuditFailed, err)
+		return fmt.Sprintf("failed to send the message to [+%s]", phone)
+	}
+	b.audit(message, phone, text, AuditSent, nil)
+	b.trackDelivery(message.Chat.ID, phone, result.MsgId)
+
+	waMsg.MsgId = result.MsgId
+	waMsg.Message = text
+	waMsg.Outgoing = true
+	b.saveCorrelation(*message, waMsg)
+
+	// replies to the confirmation message continue the conversation
+	confirmationText := fmt.Sprintf("message has been sent to [+%s]. "+
+		"reply to this message to continue the conversation", phone)
+	confirmation, err := b.replyTo(message, confirmationText)
+	if err != nil {
+		b.log.Warn(fmt.Sprintf("failed to reply the [%s] command -> %s", cmdSend, err.Error()))
+		return ""
+	}
+	waMsg.Outgoing = false
+	waMsg.Message = ""
+	b.saveCorrelation(confirmation, waMsg)
+	b.attachDeliveryStatus(result.MsgId, confirmation.MessageID, confirmationText)
+
+	return ""
+}
+
+// cmdStatus shows the whatsapp session and gateway health
+func (b *TelegramBot) cmdStatus() string {
+	depth := b.QueueDepth()
+	queue := fmt.Sprintf("Telegram send queue: %d", depth.Total)
+	if depth.Pending > 0 {
+		queue += fmt.Sprintf(" (%d pending)", depth.Pending)
+	}
+	if b.Messenger == nil {
+		return fmt.Sprintf("WhatsApp gateway: %s\n%s", noMessengerReply, queue)
+	}
+
+	status, err := b.Messenger.GetStatus(context.Background())
+	if err != nil {
+		return fmt.Sprintf("WhatsApp gateway: unreachable (%s)\n%s", err.Error(), queue)
+	}
+
+	return fmt.Sprintf("WhatsApp gateway: OK\n"+
+		"Session: +%s\n"+
+		"Connected: %t\n"+
+		"Logged in: %t\n"+
+		"%s",
+		strings.TrimPrefix(status.Phone, "+"), status.Connected, status.LoggedIn, queue)
+}
+
+// cmdHistory shows the recent messages of the designated phone: /history <phone> [n]
+func (b *TelegramBot) cmdHistory(message *tgBotApi.Message) string {
+	args := strings.Fields(message.CommandArguments())
+	if len(args) < 1 {
+		return "usage: /history <phone> [n]"
+	}
+
+	// only the authorized agents are allowed to read the conversations
+	if !b.authorizeAgent(message) {
+		return "you are not allowed to read the history of the WhatsApp contacts"
+	}
+	if b.Correlations == nil {
+		return "history is not available, since the correlation store is not configured"
+	}
+
+	phone, err := ph.ToJIDUser(args[0], ph.DefaultRegion)
+	if err != nil {
+		return fmt.Sprintf("invalid phone [%s]: %s", args[0], err.Error())
+	}
+
+	limit := defaultHistorySize
+	if len(args) > 1 {
+		limit, err = strconv.Atoi(args[1])
+		if err != nil || limit < 1 {
+			return fmt.Sprintf("invalid number of messages [%s]", args[1])
+		}
+		if limit > maxHistoryLimit {
+			limit = maxHistoryLimit
+		}
+	}
+
+	correlations, err := b.Correlations.Recent(phone, limit)
+	if err != nil {
+		b.log.Warn(fmt.Sprintf("failed to get the history of [%s] -> %s", phone, err.Error()))
+		return fmt.Sprintf("failed to get the history of [+%s]", phone)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("History of [+%s]:\n", phone))
+	count := 0
+
+	// prints the oldest message first
+	for i := len(correlations) - 1; i >= 0; i-- {
+		c := correlations[i]
+		if c.Message == "" {
+			continue
+		}
+
+		direction := "<-"
+		if c.Outgoing {
+			direction = "->"
+		}
+		sb.WriteString(fmt.Sprintf("\n[%s] %s %s", c.CreatedAt.Format("2006-01-02 15:04"), direction, c.Message))
+		count++
+	}
+	if count == 0 {
+		return fmt.Sprintf("no messages found for [+%s]", phone)
+	}
+
+	return sb.String()
+}
+
+// cmdWhois shows the contact info of the designated phone: /whois <phone>
+func (b *TelegramBot) cmdWhois(arguments string) string {
+	args := strings.Fields(arguments)
+	if len(args) < 1 {
+		return "usage: /whois <phone>"
+	}
+	if b.Messenger == nil {
+		return noMessengerReply
+	}
+
+	phone, err := ph.ToJIDUser(args[0], ph.DefaultRegion)
+	if err != nil {
+		return fmt.Sprintf("invalid phone [%s]: %s", args[0], err.Error())
+	}
+
+	contact, err := b.Messenger.GetContact(context.Background(), ph.FromJIDUser(phone))
+	if err != nil {
+		b.log.Warn(fmt.Sprintf("failed to get the contact info of [%s] -> %s", phone, err.Error()))
+		return fmt.Sprintf("failed to get the contact info of [+%s]", phone)
+	}
+	if !contact.OnWhatsapp {
+		return fmt.Sprintf("[+%s] is not available in WhatsApp", phone)
+	}
+
+	return fmt.Sprintf("Phone: +%s\n"+
+		"On contact list: %t\n"+
+		"Name: %s\n"+
+		"Push name: %s\n"+
+		"Business name: %s\n"+
+		"About: %s",
+		phone, contact.InContacts, valueOrDash(contact.FullName), valueOrDash(contact.PushName),
+		valueOrDash(contact.BusinessName), valueOrDash(contact.About))
+}
+
+// valueOrDash returns a dash if the value is empty
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
@@ -0,0 +1,121 @@
+package telegrambot
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	tgBotApi "github.com/matterbridge/telegram-bot-api/v6"
+
+	m "github.com/ardihikaru/go-modules/pkg/messenger"
+)
+
+// commandMessage builds the message of the command sent to the configured group
+func commandMessage(text string) *tgBotApi.Message {
+	command := text
+	for i, c := range text {
+		if c == ' ' || c == '\n' {
+			command = text[:i]
+			break
+		}
+	}
+
+	return &tgBotApi.Message{
+		MessageID: 5,
+		From:      &tgBotApi.User{ID: 7, FirstName: "agent"},
+		Chat:      &tgBotApi.Chat{ID: testGroupChatId, Type: "supergroup", Title: "agents"},
+		Text:      text,
+		Entities:  []tgBotApi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}},
+	}
+}
+
+func TestCommandsWithoutMessenger(t *testing.T) {
+	b := newTestBot(t, newFakeTelegramAPI(t))
+	b.Messenger = nil
+
+	if reply := b.cmdStatus(); reply != "WhatsApp gateway: messenger is not configured\nTelegram send queue: 0" {
+		t.Fatalf("unexpected status %q", reply)
+	}
+	if reply := b.cmdWhois("081234567890"); reply != noMessengerReply {
+		t.Fatalf("unexpected whois %q", reply)
+	}
+	if reply := b.cmdSend(commandMessage("/send 081234567890 hello")); reply != noMessengerReply {
+		t.Fatalf("unexpected send %q", reply)
+	}
+}
+
+func TestSendKeepsTextVerbatim(t *testing.T) {
+	b := newTestBot(t, newFakeTelegramAPI(t))
+	sender := b.Messenger.(*m.RecordingSender)
+
+	for text, want := range map[string]string{
+		"/send 081234567890 hello":                 "hello",
+		"/send  081234567890   hello  world":       "hello  world",
+		"/send 081234567890\nline one\n\nline two": "line one\n\nline two",
+	} {
+		sender.Reset()
+		if reply := b.cmdSend(commandMessage(text)); reply != "" {
+			t.Fatalf("unexpected reply %q", reply)
+		}
+
+		sent := sender.Sent()
+		if len(sent) != 1 || sent[0].Message != want || sent[0].Phone != "+6281234567890" {
+			t.Fatalf("expected %q to be sent, got %+v", want, sent)
+		}
+	}
+
+	if reply := b.cmdSend(commandMessage("/send 081234567890  ")); reply != "usage: /send <phone> <text>" {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+}
+
+func TestSendQueuesToOutbox(t *testing.T) {
+	sender := &m.RecordingSender{}
+	b, store := newOutboxTestBot(t, sender)
+	correlations, err := NewSQLiteCorrelationStore(filepath.Join(t.TempDir(), "correlations.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Correlations = correlations
+
+	if reply := b.cmdSend(commandMessage("/send 081234567890 hello")); reply != "" {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+	if sent := sender.Sent(); len(sent) != 0 {
+		t.Fatalf("expected the message to be queued rather than sent, got %+v", sent)
+	}
+
+	entries, err := store.Claim(time.Now().UTC().Add(time.Hour), time.Minute, 10)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected a queued entry, got %d, %v", len(entries), err)
+	}
+	if entries[0].Phone != "6281234567890" || entries[0].Message != "hello" || entries[0].StatusMsgId != 10 {
+		t.Fatalf("unexpected entry %+v", entries[0])
+	}
+
+	// the status message continues the conversation
+	corr, err := correlations.Get(testGroupChatId, entries[0].StatusMsgId)
+	if err != nil || corr.Phone != "6281234567890" {
+		t.Fatalf("expected the status message to be correlated, got %+v, %v", corr, err)
+	}
+}
+
+func TestHistoryRequiresAuthorizedAgent(t *testing.T) {
+	b := newTestBot(t, newFakeTelegramAPI(t))
+	correlations, err := NewSQLiteCorrelationStore(filepath.Join(t.TempDir(), "correlations.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Correlations = correlations
+	b.Agents = &AgentPolicy{AllowedUserIds: []int64{8}}
+
+	message := commandMessage("/history 081234567890")
+	if reply := b.cmdHistory(message); reply != "you are not allowed to read the history of the WhatsApp contacts" {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+
+	b.Agents.AllowedUserIds = append(b.Agents.AllowedUserIds, message.From.ID)
+	if reply := b.cmdHistory(message); reply != "no messages found for [+6281234567890]" {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+}
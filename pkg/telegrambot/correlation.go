@@ -0,0 +1,35 @@
+package telegrambot
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrCorrelationNotFound is returned when the telegram message has no whatsapp correlation
+var ErrCorrelationNotFound = errors.New("message correlation not found")
+
+// Correlation maps a telegram message posted by the bot with its originated whatsapp message
+type Correlation struct {
+	TgChatId  int64     `json:"tg_chat_id"`
+	TgMsgId   int       `json:"tg_msg_id"`
+	WaSession string    `json:"wa_session"`
+	WaChatJID string    `json:"wa_chat_jid"`
+	WaMsgId   string    `json:"wa_msg_id"`
+	Phone     string    `json:"phone"`
+	Name      string    `json:"name"`
+	Message   string    `json:"message"`
+	Outgoing  bool      `json:"outgoing"` // true if it is sent by an agent to whatsapp
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CorrelationStore defines the storage of the message correlations
+type CorrelationStore interface {
+	// Save stores the correlation of the posted telegram message
+	Save(c *Correlation) error
+
+	// Get gets the correlation of the designated telegram message, returns ErrCorrelationNotFound if not exists
+	Get(tgChatId int64, tgMsgId int) (*Correlation, error)
+
+	// Recent gets the most recent correlations of the designated whatsapp phone, the newest comes first
+	Recent(phone string, limit int) ([]*Correlation, error)
+}
@@ -0,0 +1,144 @@
+package telegrambot
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	goRedis "github.com/go-redis/redis"
+
+	"github.com/ardihikaru/go-modules/pkg/redis"
+)
+
+// prefixes of the redis keys
+const (
+	correlationKeyPrefix = "tgbot:correlation"
+	historyKeyPrefix     = "tgbot:history"
+	topicKeyPrefix       = "tgbot:topic"
+)
+
+// maxHistorySize defines the maximum number of correlations kept in the history of each whatsapp phone
+const maxHistorySize = 100
+
+// RedisCorrelationStore stores the message correlations and the forum topics in redis
+type RedisCorrelationStore struct {
+	rdb *redis.Redis
+	ttl time.Duration
+}
+
+// NewRedisCorrelationStore builds the redis correlation store
+// the correlations expire after the designated TTL, zero means that they never expire
+func NewRedisCorrelationStore(rdb *redis.Redis, ttl time.Duration) *RedisCorrelationStore {
+	return &RedisCorrelationStore{rdb: rdb, ttl: ttl}
+}
+
+// Save stores the correlation of the posted telegram message
+func (s *RedisCorrelationStore) Save(c *Correlation) error {
+	err := s.rdb.Set(correlationKey(c.TgChatId, c.TgMsgId), c, s.ttl)
+	if err != nil {
+		return err
+	}
+
+	// keeps the latest correlations of the whatsapp phone as its history
+	p, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	historyKey := fmt.Sprintf("%s:%s", historyKeyPrefix, c.Phone)
+	pipe := s.rdb.Client.TxPipeline()
+	pipe.LPush(historyKey, p)
+	pipe.LTrim(historyKey, 0, maxHistorySize-1)
+	if s.ttl > 0 {
+		pipe.Expire(historyKey, s.ttl)
+	}
+	_, err = pipe.Exec()
+
+	return err
+}
+
+// Get gets the correlation of the designated telegram message
+func (s *RedisCorrelationStore) Get(tgChatId int64, tgMsgId int) (*Correlation, error) {
+	var c Correlation
+
+	err := s.rdb.Get(correlationKey(tgChatId, tgMsgId), &c)
+	if err == goRedis.Nil {
+		return nil, ErrCorrelationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// Recent gets the most recent correlations of the designated whatsapp phone
+func (s *RedisCorrelationStore) Recent(phone string, limit int) ([]*Correlation, error) {
+	vals, err := s.rdb.Client.LRange(fmt.Sprintf("%s:%s", historyKeyPrefix, phone), 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	correlations := make([]*Correlation, 0, len(vals))
+	for _, val := range vals {
+		var c Correlation
+		err = json.Unmarshal([]byte(val), &c)
+		if err != nil {
+			return nil, err
+		}
+		correlations = append(correlations, &c)
+	}
+
+	return correlations, nil
+}
+
+// SaveTopic stores the forum topic of the whatsapp conversation
+// topics never expire, since the conversation should always be posted into the same topic
+func (s *RedisCorrelationStore) SaveTopic(t *Topic) error {
+	err := s.rdb.Set(topicPhoneKey(t.TgChatId, t.Phone), t, 0)
+	if err != nil {
+		return err
+	}
+
+	return s.rdb.Set(topicThreadKey(t.TgChatId, t.ThreadId), t, 0)
+}
+
+// GetTopicByPhone gets the forum topic of the designated whatsapp phone
+func (s *RedisCorrelationStore) GetTopicByPhone(tgChatId int64, phone string) (*Topic, error) {
+	return s.getTopic(topicPhoneKey(tgChatId, phone))
+}
+
+// GetTopicByThread gets the forum topic of the designated thread
+func (s *RedisCorrelationStore) GetTopicByThread(tgChatId int64, threadId int) (*Topic, error) {
+	return s.getTopic(topicThreadKey(tgChatId, threadId))
+}
+
+// getTopic gets the forum topic of the designated key
+func (s *RedisCorrelationStore) getTopic(key string) (*Topic, error) {
+	var t Topic
+
+	err := s.rdb.Get(key, &t)
+	if err == goRedis.Nil {
+		return nil, ErrTopicNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// topicPhoneKey builds the redis key of the forum topic of the designated whatsapp phone
+func topicPhoneKey(tgChatId int64, phone string) string {
+	return fmt.Sprintf("%s:%d:phone:%s", topicKeyPrefix, tgChatId, phone)
+}
+
+// topicThreadKey builds the redis key of the forum topic of the designated thread
+func topicThreadKey(tgChatId int64, threadId int) string {
+	return fmt.Sprintf("%s:%d:thread:%d", topicKeyPrefix, tgChatId, threadId)
+}
+
+// correlationKey builds the redis key of the designated telegram message
+func correlationKey(tgChatId int64, tgMsgId int) string {
+	return fmt.Sprintf("%s:%d:%d", correlationKeyPrefix, tgChatId, tgMsgId)
+}
@@ -0,0 +1,177 @@
+package telegrambot
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SQLiteCorrelationStore stores the message correlations and the forum topics in a SQLite database
+type SQLiteCorrelationStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteCorrelationStore builds the SQLite correlation store and prepares the tables
+func NewSQLiteCorrelationStore(dbName string) (*SQLiteCorrelationStore, error) {
+	db, err := openSQLite(dbName,
+		`CREATE TABLE IF NOT EXISTS tg_correlations (
+		tg_chat_id  INTEGER NOT NULL,
+		tg_msg_id   INTEGER NOT NULL,
+		wa_session  TEXT NOT NULL,
+		wa_chat_jid TEXT NOT NULL,
+		wa_msg_id   TEXT NOT NULL,
+		phone       TEXT NOT NULL,
+		name        TEXT NOT NULL,
+		message     TEXT NOT NULL DEFAULT '',
+		outgoing    BOOLEAN NOT NULL DEFAULT 0,
+		created_at  TIMESTAMP NOT NULL,
+		PRIMARY KEY (tg_chat_id, tg_msg_id)
+	)`,
+		`CREATE INDEX IF NOT EXISTS tg_correlations_phone ON tg_correlations (phone, created_at)`,
+		`CREATE TABLE IF NOT EXISTS tg_topics (
+		tg_chat_id  INTEGER NOT NULL,
+		thread_id   INTEGER NOT NULL,
+		wa_session  TEXT NOT NULL,
+		wa_chat_jid TEXT NOT NULL,
+		phone       TEXT NOT NULL,
+		name        TEXT NOT NULL,
+		created_at  TIMESTAMP NOT NULL,
+		PRIMARY KEY (tg_chat_id, thread_id),
+		UNIQUE (tg_chat_id, phone)
+	)`)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQLiteCorrelationStore{db: db}, nil
+}
+
+// openSQLite opens the SQLite database and executes the designated schema statements
+// the stores may share the same database, since each of them only creates its own tables
+func openSQLite(dbName string, statements ...string) (*sql.DB, error) {
+	address := fmt.Sprintf("file:%s.db?_foreign_keys=on", dbName)
+
+	db, err := sql.Open("sqlite3", address)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, statement := range statements {
+		_, err = db.Exec(statement)
+		if err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+
+	return db, nil
+}
+
+// Save stores the correlation of the posted telegram message
+func (s *SQLiteCorrelationStore) Save(c *Correlation) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO tg_correlations
+		(tg_chat_id, tg_msg_id, wa_session, wa_chat_jid, wa_msg_id, phone, name, message, outgoing, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.TgChatId, c.TgMsgId, c.WaSession, c.WaChatJID, c.WaMsgId, c.Phone, c.Name, c.Message, c.Outgoing,
+		c.CreatedAt)
+
+	return err
+}
+
+// Get gets the correlation of the designated telegram message
+func (s *SQLiteCorrelationStore) Get(tgChatId int64, tgMsgId int) (*Correlation, error) {
+	c, err := scanCorrelation(s.db.QueryRow(`SELECT `+correlationColumns+`
+		FROM tg_correlations WHERE tg_chat_id = ? AND tg_msg_id = ?`, tgChatId, tgMsgId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCorrelationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Recent gets the most recent correlations of the designated whatsapp phone
+func (s *SQLiteCorrelationStore) Recent(phone string, limit int) ([]*Correlation, error) {
+	rows, err := s.db.Query(`SELECT `+correlationColumns+`
+		FROM tg_correlations WHERE phone = ? ORDER BY created_at DESC LIMIT ?`, phone, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var correlations []*Correlation
+	for rows.Next() {
+		c, err := scanCorrelation(rows)
+		if err != nil {
+			return nil, err
+		}
+		correlations = append(correlations, c)
+	}
+
+	return correlations, rows.Err()
+}
+
+// correlationColumns lists the selected columns of the correlation
+const correlationColumns = `tg_chat_id, tg_msg_id, wa_session, wa_chat_jid, wa_msg_id, phone, name, message, outgoing,
+	created_at`
+
+// scanCorrelation scans the selected correlation columns
+func scanCorrelation(row interface {
+	Scan(dest ...interface{}) error
+}) (*Correlation, error) {
+	var c Correlation
+
+	err := row.Scan(&c.TgChatId, &c.TgMsgId, &c.WaSession, &c.WaChatJID, &c.WaMsgId, &c.Phone, &c.Name, &c.Message,
+		&c.Outgoing, &c.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// SaveTopic stores the forum topic of the whatsapp conversation
+func (s *SQLiteCorrelationStore) SaveTopic(t *Topic) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO tg_topics
+		(tg_chat_id, thread_id, wa_session, wa_chat_jid, phone, name, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		t.TgChatId, t.ThreadId, t.WaSession, t.WaChatJID, t.Phone, t.Name, t.CreatedAt)
+
+	return err
+}
+
+// GetTopicByPhone gets the forum topic of the designated whatsapp phone
+func (s *SQLiteCorrelationStore) GetTopicByPhone(tgChatId int64, phone string) (*Topic, error) {
+	return s.getTopic(`WHERE tg_chat_id = ? AND phone = ?`, tgChatId, phone)
+}
+
+// GetTopicByThread gets the forum topic of the designated thread
+func (s *SQLiteCorrelationStore) GetTopicByThread(tgChatId int64, threadId int) (*Topic, error) {
+	return s.getTopic(`WHERE tg_chat_id = ? AND thread_id = ?`, tgChatId, threadId)
+}
+
+// getTopic gets the forum topic with the designated condition
+func (s *SQLiteCorrelationStore) getTopic(where string, args ...interface{}) (*Topic, error) {
+	var t Topic
+
+	err := s.db.QueryRow(`SELECT tg_chat_id, thread_id, wa_session, wa_chat_jid, phone, name, created_at
+		FROM tg_topics `+where, args...).
+		Scan(&t.TgChatId, &t.ThreadId, &t.WaSession, &t.WaChatJID, &t.Phone, &t.Name, &t.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTopicNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// Close closes the database connection
+func (s *SQLiteCorrelationStore) Close() error {
+	return s.db.Close()
+}
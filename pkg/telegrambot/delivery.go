@@ -0,0 +1,193 @@
+package telegrambot
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	tgBotApi "github.com/matterbridge/telegram-bot-api/v6"
+
+	m "github.com/ardihikaru/go-modules/pkg/messenger"
+	"github.com/ardihikaru/go-modules/pkg/utils/httputils"
+)
+
+// ErrDeliveryNotFound is returned when the whatsapp message is not tracked
+var ErrDeliveryNotFound = errors.New("message delivery not found")
+
+// Delivery tracks the delivery status of an agent reply sent to whatsapp
+type Delivery struct {
+	WaMsgId     string    `json:"wa_msg_id"`
+	Phone       string    `json:"phone"`
+	TgChatId    int64     `json:"tg_chat_id"`
+	StatusMsgId int       `json:"status_msg_id"` // the bot message edited with the delivery status, zero if not posted yet
+	StatusText  string    `json:"status_text"`   // the original text of the status message
+	Receipt     string    `json:"receipt"`       // the latest receipt, e.g. messenger.ReceiptRead
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// DeliveryStore defines the storage of the delivery status, keyed by the whatsapp message ID
+type DeliveryStore interface {
+	// SaveDelivery stores the delivery status
+	SaveDelivery(d *Delivery) error
+
+	// GetDelivery gets the delivery status of the whatsapp message, returns ErrDeliveryNotFound if not exists
+	GetDelivery(waMsgId string) (*Delivery, error)
+
+	// MergeDelivery atomically merges the update into the stored delivery status (see mergeDelivery),
+	// and returns the merged one and whether it is changed; returns ErrDeliveryNotFound if not exists
+	MergeDelivery(update *Delivery) (*Delivery, bool, error)
+}
+
+// mergeDelivery merges the update into the delivery status, and returns whether it is changed
+// the status message is set if the update has one, and the receipt is only raised, never lowered,
+// so that the status message and the receipts may be reported in any order
+func mergeDelivery(d, update *Delivery) bool {
+	changed := false
+	if update.StatusMsgId != 0 && (update.StatusMsgId != d.StatusMsgId || update.StatusText != d.StatusText) {
+		d.StatusMsgId = update.StatusMsgId
+		d.StatusText = update.StatusText
+		changed = true
+	}
+
+	// receipts may arrive out of order, e.g. read before delivered
+	if m.ReceiptRank(update.Receipt) > m.ReceiptRank(d.Receipt) {
+		d.Receipt = update.Receipt
+		d.UpdatedAt = update.UpdatedAt
+		changed = true
+	}
+
+	return changed
+}
+
+// deliveryStatusText builds the text of the status message with the latest receipt
+func deliveryStatusText(d *Delivery) string {
+	return fmt.Sprintf("%s\nstatus: %s", d.StatusText, d.Receipt)
+}
+
+// trackDelivery starts tracking the sent whatsapp message, before its status message is posted,
+// so that the receipts arriving meanwhile are not lost
+func (b *TelegramBot) trackDelivery(tgChatId int64, phone, waMsgId string) {
+	if b.Deliveries == nil || waMsgId == "" {
+		return
+	}
+
+	err := b.Deliveries.SaveDelivery(&Delivery{
+		WaMsgId:   waMsgId,
+		Phone:     phone,
+		TgChatId:  tgChatId,
+		Receipt:   m.ReceiptSent,
+		UpdatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		b.log.Warn(fmt.Sprintf("failed to store the message delivery -> %s", err.Error()))
+	}
+}
+
+// attachDeliveryStatus attaches the posted status message to the tracked whatsapp message,
+// the status message is edited right away if a receipt has arrived meanwhile
+func (b *TelegramBot) attachDeliveryStatus(waMsgId string, statusMsgId int, statusText string) {
+	if b.Deliveries == nil || waMsgId == "" || statusMsgId == 0 {
+		return
+	}
+
+	d, _, err := b.Deliveries.MergeDelivery(&Delivery{
+		WaMsgId:     waMsgId,
+		StatusMsgId: statusMsgId,
+		StatusText:  statusText,
+	})
+	if err != nil {
+		b.log.Warn(fmt.Sprintf("failed to store the status message of delivery [%s] -> %s", waMsgId, err.Error()))
+		return
+	}
+
+	// the receipt merged before the status message is not reflected by HandleReceipt
+	if m.ReceiptRank(d.Receipt) > m.ReceiptRank(m.ReceiptSent) {
+		b.updateDeliveryStatus(d)
+	}
+}
+
+// HandleReceipt reflects the delivery receipt on the status message of each tracked whatsapp message
+// the untracked messages (e.g. not sent by the agents) are ignored
+func (b *TelegramBot) HandleReceipt(r *m.DeliveryReceipt) error {
+	if b.Deliveries == nil {
+		return nil
+	}
+	if m.ReceiptRank(r.Receipt) == 0 {
+		return fmt.Errorf("unknown receipt [%s]", r.Receipt)
+	}
+
+	updatedAt := r.Timestamp.UTC()
+	if r.Timestamp.IsZero() {
+		updatedAt = time.Now().UTC()
+	}
+
+	for _, msgId := range r.MsgIds {
+		d, changed, err := b.Deliveries.MergeDelivery(&Delivery{
+			WaMsgId:   msgId,
+			Receipt:   r.Receipt,
+			UpdatedAt: updatedAt,
+		})
+		if errors.Is(err, ErrDeliveryNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		// the status message not attached yet is edited by attachDeliveryStatus
+		if changed {
+			b.updateDeliveryStatus(d)
+		}
+	}
+
+	return nil
+}
+
+// updateDeliveryStatus edits the status message with the latest receipt
+func (b *TelegramBot) updateDeliveryStatus(d *Delivery) {
+	if d.StatusMsgId == 0 {
+		return
+	}
+
+	_, err := b.send(d.TgChatId, tgBotApi.NewEditMessageText(d.TgChatId, d.StatusMsgId, deliveryStatusText(d)))
+	if err != nil {
+		b.log.Warn(fmt.Sprintf("failed to update the delivery status message -> %s", err.Error()))
+	}
+}
+
+// DeliveryStatusHandler builds the HTTP handler of the status callback, which receives
+// the delivery receipts (see messenger.DeliveryReceipt) posted by the whatsapp gateway
+func (b *TelegramBot) DeliveryStatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var receipt m.DeliveryReceipt
+		appErrCode, httpStatusCode, err := httputils.GetJsonBody(r.Body, &receipt)
+		if err != nil {
+			httputils.RenderErrResponse(w, r, httputils.ResponseText("", appErrCode), int64(appErrCode),
+				httpStatusCode, err)
+			return
+		}
+
+		if m.ReceiptRank(receipt.Receipt) == 0 {
+			httputils.RenderErrResponse(w, r, httputils.ResponseText("", httputils.InputValidationError),
+				httputils.InputValidationError, http.StatusBadRequest,
+				fmt.Errorf("unknown receipt [%s]", receipt.Receipt))
+			return
+		}
+
+		err = b.HandleReceipt(&receipt)
+		if err != nil {
+			b.log.Warn(fmt.Sprintf("failed to handle the [%s] receipt -> %s", receipt.Receipt, err.Error()))
+			httputils.RenderErrResponse(w, r, httputils.ResponseText("", httputils.UpdateDataFailed),
+				httputils.UpdateDataFailed, http.StatusInternalServerError, err)
+			return
+		}
+
+		_ = httputils.RenderOKResponse(w, r, httputils.Response{})
+	})
+}
@@ -0,0 +1,107 @@
+package telegrambot
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	goRedis "github.com/go-redis/redis"
+
+	"github.com/ardihikaru/go-modules/pkg/redis"
+)
+
+// deliveryKeyPrefix is the prefix of the redis keys of the delivery status
+const deliveryKeyPrefix = "tgbot:delivery"
+
+// maxDeliveryTxRetries defines the number of retries of a delivery transaction conflicting with another update
+const maxDeliveryTxRetries = 10
+
+// RedisDeliveryStore stores the delivery status in redis
+type RedisDeliveryStore struct {
+	rdb *redis.Redis
+	ttl time.Duration
+}
+
+// NewRedisDeliveryStore builds the redis delivery store
+// the delivery status expires after the designated TTL (e.g. as the correlations do), zero means that it never expires
+func NewRedisDeliveryStore(rdb *redis.Redis, ttl time.Duration) *RedisDeliveryStore {
+	return &RedisDeliveryStore{rdb: rdb, ttl: ttl}
+}
+
+// SaveDelivery stores the delivery status, it expires after the TTL
+func (s *RedisDeliveryStore) SaveDelivery(d *Delivery) error {
+	return s.rdb.Set(deliveryKey(d.WaMsgId), d, s.ttl)
+}
+
+// GetDelivery gets the delivery status of the whatsapp message
+func (s *RedisDeliveryStore) GetDelivery(waMsgId string) (*Delivery, error) {
+	var d Delivery
+
+	err := s.rdb.Get(deliveryKey(waMsgId), &d)
+	if err == goRedis.Nil {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// MergeDelivery merges the update into the stored delivery status in an optimistic transaction,
+// which is retried if the delivery is changed by another update meanwhile
+func (s *RedisDeliveryStore) MergeDelivery(update *Delivery) (*Delivery, bool, error) {
+	key := deliveryKey(update.WaMsgId)
+
+	var merged *Delivery
+	var changed bool
+	fn := func(tx *goRedis.Tx) error {
+		val, err := tx.Get(key).Result()
+		if err == goRedis.Nil {
+			return ErrDeliveryNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var d Delivery
+		err = json.Unmarshal([]byte(val), &d)
+		if err != nil {
+			return err
+		}
+		merged, changed = &d, mergeDelivery(&d, update)
+		if !changed {
+			return nil
+		}
+
+		p, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Pipelined(func(pipe goRedis.Pipeliner) error {
+			pipe.Set(key, p, s.ttl)
+			return nil
+		})
+
+		return err
+	}
+
+	for i := 0; i < maxDeliveryTxRetries; i++ {
+		err := s.rdb.Client.Watch(fn, key)
+		if err == goRedis.TxFailedErr {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		return merged, changed, nil
+	}
+
+	return nil, false, goRedis.TxFailedErr
+}
+
+// deliveryKey builds the redis key of the delivery status of the designated whatsapp message
+func deliveryKey(waMsgId string) string {
+	return fmt.Sprintf("%s:%s", deliveryKeyPrefix, waMsgId)
+}
@@ -0,0 +1,95 @@
+package telegrambot
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+)
+
+// SQLiteDeliveryStore stores the delivery status in a SQLite database
+type SQLiteDeliveryStore struct {
+	db *sql.DB
+
+	// mu serializes the merges, the database is not meant to be shared by multiple processes
+	mu sync.Mutex
+}
+
+// NewSQLiteDeliveryStore builds the SQLite delivery store and prepares the table
+func NewSQLiteDeliveryStore(dbName string) (*SQLiteDeliveryStore, error) {
+	db, err := openSQLite(dbName,
+		`CREATE TABLE IF NOT EXISTS tg_deliveries (
+		wa_msg_id     TEXT NOT NULL PRIMARY KEY,
+		phone         TEXT NOT NULL,
+		tg_chat_id    INTEGER NOT NULL,
+		status_msg_id INTEGER NOT NULL,
+		status_text   TEXT NOT NULL,
+		receipt       TEXT NOT NULL,
+		updated_at    TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQLiteDeliveryStore{db: db}, nil
+}
+
+// SaveDelivery stores the delivery status
+func (s *SQLiteDeliveryStore) SaveDelivery(d *Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.saveDelivery(d)
+}
+
+// MergeDelivery merges the update into the stored delivery status
+func (s *SQLiteDeliveryStore) MergeDelivery(update *Delivery) (*Delivery, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, err := s.GetDelivery(update.WaMsgId)
+	if err != nil {
+		return nil, false, err
+	}
+	if !mergeDelivery(d, update) {
+		return d, false, nil
+	}
+
+	err = s.saveDelivery(d)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return d, true, nil
+}
+
+// saveDelivery stores the delivery status, the caller holds the lock
+func (s *SQLiteDeliveryStore) saveDelivery(d *Delivery) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO tg_deliveries
+		(wa_msg_id, phone, tg_chat_id, status_msg_id, status_text, receipt, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		d.WaMsgId, d.Phone, d.TgChatId, d.StatusMsgId, d.StatusText, d.Receipt, d.UpdatedAt)
+
+	return err
+}
+
+// GetDelivery gets the delivery status of the whatsapp message
+func (s *SQLiteDeliveryStore) GetDelivery(waMsgId string) (*Delivery, error) {
+	var d Delivery
+
+	err := s.db.QueryRow(`SELECT wa_msg_id, phone, tg_chat_id, status_msg_id, status_text, receipt, updated_at
+		FROM tg_deliveries WHERE wa_msg_id = ?`, waMsgId).
+		Scan(&d.WaMsgId, &d.Phone, &d.TgChatId, &d.StatusMsgId, &d.StatusText, &d.Receipt, &d.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// Close closes the database connection
+func (s *SQLiteDeliveryStore) Close() error {
+	return s.db.Close()
+}
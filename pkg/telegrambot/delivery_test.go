@@ -0,0 +1,125 @@
+package telegrambot
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	m "github.com/ardihikaru/go-modules/pkg/messenger"
+	"github.com/ardihikaru/go-modules/pkg/redis"
+)
+
+// newDeliveryStores builds the SQLite and the redis delivery stores
+func newDeliveryStores(t *testing.T) map[string]DeliveryStore {
+	t.Helper()
+
+	sqliteStore, err := NewSQLiteDeliveryStore(filepath.Join(t.TempDir(), "deliveries.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = sqliteStore.Close() })
+
+	mr := miniredis.RunT(t)
+	rdb, err := redis.GetRedis(mr.Addr(), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = rdb.Client.Close() })
+
+	return map[string]DeliveryStore{
+		"sqlite": sqliteStore,
+		"redis":  NewRedisDeliveryStore(rdb, time.Hour),
+	}
+}
+
+func TestMergeDelivery(t *testing.T) {
+	for name, store := range newDeliveryStores(t) {
+		_, _, err := store.MergeDelivery(&Delivery{WaMsgId: "missing", Receipt: m.ReceiptRead})
+		if !errors.Is(err, ErrDeliveryNotFound) {
+			t.Fatalf("%s: expected ErrDeliveryNotFound, got %v", name, err)
+		}
+
+		err = store.SaveDelivery(&Delivery{WaMsgId: "msg-1", Phone: "62811", TgChatId: testGroupChatId,
+			Receipt: m.ReceiptSent, UpdatedAt: time.Now().UTC()})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, step := range []struct {
+			update  Delivery
+			changed bool
+			receipt string
+			msgId   int
+		}{
+			{Delivery{Receipt: m.ReceiptRead, UpdatedAt: time.Now().UTC()}, true, m.ReceiptRead, 0},
+			{Delivery{Receipt: m.ReceiptDelivered, UpdatedAt: time.Now().UTC()}, false, m.ReceiptRead, 0},
+			{Delivery{StatusMsgId: 10, StatusText: "sent"}, true, m.ReceiptRead, 10},
+			{Delivery{StatusMsgId: 10, StatusText: "sent"}, false, m.ReceiptRead, 10},
+		} {
+			step.update.WaMsgId = "msg-1"
+			d, changed, err := store.MergeDelivery(&step.update)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if changed != step.changed || d.Receipt != step.receipt || d.StatusMsgId != step.msgId ||
+				d.TgChatId != testGroupChatId {
+				t.Fatalf("%s: unexpected merge of %+v: %+v, %v", name, step.update, d, changed)
+			}
+		}
+
+		d, err := store.GetDelivery("msg-1")
+		if err != nil || d.Receipt != m.ReceiptRead || d.StatusMsgId != 10 || d.StatusText != "sent" {
+			t.Fatalf("%s: unexpected stored delivery %+v, %v", name, d, err)
+		}
+	}
+}
+
+// hookDeliveryStore calls the hook before merging the status message
+type hookDeliveryStore struct {
+	DeliveryStore
+	hook func()
+}
+
+func (s *hookDeliveryStore) MergeDelivery(update *Delivery) (*Delivery, bool, error) {
+	if update.StatusMsgId != 0 && s.hook != nil {
+		s.hook()
+	}
+
+	return s.DeliveryStore.MergeDelivery(update)
+}
+
+func TestReceiptBeforeStatusMessageIsNotLost(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	b := newTestBot(t, api)
+	for name, store := range newDeliveryStores(t) {
+		// the receipt arrives while the status message is being attached
+		msgId := "msg-" + name
+		b.Deliveries = &hookDeliveryStore{DeliveryStore: store, hook: func() {
+			err := b.HandleReceipt(&m.DeliveryReceipt{MsgIds: []string{msgId}, Receipt: m.ReceiptRead})
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+			}
+		}}
+		b.trackDelivery(testGroupChatId, "62811", msgId)
+		b.attachDeliveryStatus(msgId, 10, m.SentConfirmation)
+
+		d, err := store.GetDelivery(msgId)
+		if err != nil || d.Receipt != m.ReceiptRead || d.StatusMsgId != 10 {
+			t.Fatalf("%s: expected both the receipt and the status message, got %+v, %v", name, d, err)
+		}
+	}
+
+	edits := api.called("editMessageText")
+	if len(edits) != 2 {
+		t.Fatalf("expected the status message to be edited by each store, got %d edits", len(edits))
+	}
+	for _, edit := range edits {
+		if !strings.HasSuffix(edit["text"], "status: read") {
+			t.Fatalf("unexpected edit %+v", edit)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package telegrambot
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	tgBotApi "github.com/matterbridge/telegram-bot-api/v6"
+
+	m "github.com/ardihikaru/go-modules/pkg/messenger"
+)
+
+// maxCaptionLength defines the maximum length of a media caption allowed by telegram
+const maxCaptionLength = 1024
+
+// maxMediaDownload defines the maximum size of the files sent by the agents (50 MB)
+const maxMediaDownload = 50 * 1024 * 1024
+
+// downloadClient defines the http client to download the files sent by the agents
+var downloadClient = &http.Client{Timeout: time.Minute}
+
+// media types of the forwarded whatsapp message
+const (
+	MediaImage    = "image"
+	MediaVoice    = "voice"
+	MediaAudio    = "audio"
+	MediaVideo    = "video"
+	MediaDocument = "document"
+	MediaSticker  = "sticker"
+	MediaLocation = "location"
+)
+
+// Media defines the media of the forwarded whatsapp message
+type Media struct {
+	Type      string
+	Data      []byte
+	FileName  string
+	MimeType  string
+	Latitude  float64
+	Longitude float64
+}
+
+// sendMedia posts the media with the message header as the caption
+// media without caption support (e.g. sticker, location) are posted as a reply to the message header instead,
+// in that case the message header is the returned message, so that agents can reply to it
+// a formatted header (parse mode is not empty) must have been trimmed by the message template
+func (b *TelegramBot) sendMedia(chatId int64, threadId int, header, parseMode string,
+	media *Media) (tgBotApi.Message, error) {
+	file := tgBotApi.FileBytes{Name: media.fileName(), Bytes: media.Data}
+	baseFile := tgBotApi.BaseFile{
+		BaseChat: tgBotApi.BaseChat{ChatID: chatId, MessageThreadID: threadId},
+		File:     file,
+	}
+	caption := header
+	if parseMode == "" {
+		caption = truncateCaption(header)
+	}
+
+	switch media.Type {
+	case MediaImage:
+		return b.send(chatId, tgBotApi.PhotoConfig{BaseFile: baseFile, Caption: caption, ParseMode: parseMode})
+	case MediaVoice:
+		return b.send(chatId, tgBotApi.VoiceConfig{BaseFile: baseFile, Caption: caption, ParseMode: parseMode})
+	case MediaAudio:
+		return b.send(chatId, tgBotApi.AudioConfig{BaseFile: baseFile, Caption: caption, ParseMode: parseMode})
+	case MediaVideo:
+		return b.send(chatId, tgBotApi.VideoConfig{BaseFile: baseFile, Caption: caption, ParseMode: parseMode})
+	case MediaDocument:
+		return b.send(chatId, tgBotApi.DocumentConfig{BaseFile: baseFile, Caption: caption, ParseMode: parseMode})
+	case MediaSticker, MediaLocation:
+		headerMsg := tgBotApi.NewMessage(chatId, header)
+		headerMsg.MessageThreadID = threadId
+		headerMsg.ParseMode = parseMode
+		sent, err := b.send(chatId, headerMsg)
+		if err != nil {
+			return sent, err
+		}
+
+		var chattable tgBotApi.Chattable
+		if media.Type == MediaSticker {
+			baseFile.ReplyToMessageID = sent.MessageID
+			chattable = tgBotApi.StickerConfig{BaseFile: baseFile}
+		} else {
+			location := tgBotApi.NewLocation(chatId, media.Latitude, media.Longitude)
+			location.MessageThreadID = threadId
+			location.ReplyToMessageID = sent.MessageID
+			chattable = location
+		}
+
+		_, err = b.send(chatId, chattable)
+		if err != nil {
+			b.log.Warn(fmt.Sprintf("failed to send the [%s] media -> %s", media.Type, err.Error()))
+		}
+
+		return sent, nil
+	default:
+		return tgBotApi.Message{}, fmt.Errorf("unsupported media type [%s]", media.Type)
+	}
+}
+
+// fileName returns the file name of the media, a default name is generated if it is empty
+func (m *Media) fileName() string {
+	if m.FileName != "" {
+		return m.FileName
+	}
+
+	switch m.Type {
+	case MediaImage:
+		return "image.jpg"
+	case MediaVoice:
+		return "voice.ogg"
+	case MediaAudio:
+		return "audio.mp3"
+	case MediaVideo:
+		return "video.mp4"
+	case MediaSticker:
+		return "sticker.webp"
+	default:
+		return "file"
+	}
+}
+
+// truncateCaption trims the caption, since telegram rejects long captions
+func truncateCaption(caption string) string {
+	return truncateText(caption, maxCaptionLength)
+}
+
+// replyMedia describes the media (e.g. photo, document, voice note, video) sent by the agent without its data,
+// and returns its telegram file ID; it returns nil if the message has no (supported) media
+func replyMedia(message *tgBotApi.Message) (*m.Media, string) {
+	media := &m.Media{}
+
+	switch {
+	case len(message.Photo) > 0:
+		// the last photo size is the largest one
+		media.Type, media.FileName, media.MimeType = m.MediaImage, "photo.jpg", "image/jpeg"
+		return media, message.Photo[len(message.Photo)-1].FileID
+	case message.Document != nil:
+		media.Type, media.FileName, media.MimeType = m.MediaDocument, message.Document.FileName,
+			message.Document.MimeType
+		return media, message.Document.FileID
+	case message.Voice != nil:
+		media.Type, media.FileName, media.MimeType = m.MediaVoice, "voice.ogg", message.Voice.MimeType
+		return media, message.Voice.FileID
+	case message.Video != nil:
+		media.Type, media.FileName, media.MimeType = m.MediaVideo, message.Video.FileName, message.Video.MimeType
+		return media, message.Video.FileID
+	default:
+		return nil, ""
+	}
+}
+
+// downloadMedia downloads the data of the telegram file into the media, up to maxMediaDownload
+func (b *TelegramBot) downloadMedia(fileId string, media *m.Media) error {
+	fileUrl, err := b.Bot.GetFileDirectURL(fileId)
+	if err != nil {
+		return err
+	}
+
+	resp, err := downloadClient.Get(fileUrl)
+	if err != nil {
+		// the file URL carries the bot token
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to download the telegram file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download the telegram file (status code: %d)", resp.StatusCode)
+	}
+
+	media.Data, err = io.ReadAll(io.LimitReader(resp.Body, maxMediaDownload+1))
+	if err != nil {
+		return err
+	}
+	if len(media.Data) > maxMediaDownload {
+		return fmt.Errorf("telegram file exceeds the maximum size of %d MB", maxMediaDownload/1024/1024)
+	}
+
+	return nil
+}
@@ -0,0 +1,309 @@
+package telegrambot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	tgBotApi "github.com/matterbridge/telegram-bot-api/v6"
+	"go.mau.fi/whatsmeow/types"
+
+	"github.com/ardihikaru/go-modules/pkg/enums/emoji"
+	m "github.com/ardihikaru/go-modules/pkg/messenger"
+	ph "github.com/ardihikaru/go-modules/pkg/utils/phone"
+)
+
+// defaults of the outbox worker
+const (
+	defaultOutboxInterval    = 5 * time.Second
+	defaultOutboxMaxAttempts = 10
+	defaultOutboxBackoff     = 10 * time.Second
+	defaultOutboxMaxBackoff  = 10 * time.Minute
+	defaultOutboxBatchSize   = 20
+	defaultOutboxLease       = time.Minute
+)
+
+// OutboxEntry defines an agent reply waiting to be delivered to whatsapp
+type OutboxEntry struct {
+	Id          string    `json:"id"`
+	TgChatId    int64     `json:"tg_chat_id"`
+	TgMsgId     int       `json:"tg_msg_id"`
+	TgUserId    int64     `json:"tg_user_id"`
+	TgUsername  string    `json:"tg_username"`
+	StatusMsgId int       `json:"status_msg_id"` // the bot message edited with the delivery status
+	Phone       string    `json:"phone"`
+	Message     string    `json:"message"`
+	Media       *m.Media  `json:"media,omitempty"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error,omitempty"`
+	ReceivedAt  time.Time `json:"received_at"`
+	NextAttempt time.Time `json:"next_attempt"`
+}
+
+// OutboxStore defines the storage of the agent replies waiting to be delivered
+type OutboxStore interface {
+	// Enqueue stores the new entry, due immediately
+	Enqueue(e *OutboxEntry) error
+
+	// Claim gets the due entries, and postpones them by the lease so that other workers skip them meanwhile
+	Claim(now time.Time, lease time.Duration, limit int) ([]*OutboxEntry, error)
+
+	// Update stores the entry to be retried on its next attempt
+	Update(e *OutboxEntry) error
+
+	// Remove removes the delivered (or given up) entry
+	Remove(id string) error
+}
+
+// OutboxConfig defines the configuration of the outbox worker, the zero values use the defaults
+type OutboxConfig struct {
+	// Interval is the polling interval of the due entries
+	Interval time.Duration
+
+	// MaxAttempts is the number of attempts before giving up
+	MaxAttempts int
+
+	// Backoff is the delay before the first retry, it is doubled on each retry up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// BatchSize is the maximum number of entries delivered on each poll
+	BatchSize int
+
+	// Lease is how long a claimed entry is hidden from other workers, the sending of an entry
+	// (including the retries of the messenger) is cancelled once it passes
+	Lease time.Duration
+}
+
+// withDefaults fills the zero values with the defaults
+func (c OutboxConfig) withDefaults() OutboxConfig {
+	if c.Interval <= 0 {
+		c.Interval = defaultOutboxInterval
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = defaultOutboxMaxAttempts
+	}
+	if c.Backoff <= 0 {
+		c.Backoff = defaultOutboxBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = defaultOutboxMaxBackoff
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaultOutboxBatchSize
+	}
+	if c.Lease <= 0 {
+		c.Lease = defaultOutboxLease
+	}
+
+	return c
+}
+
+// backoff returns the delay before the next attempt
+func (c OutboxConfig) backoff(attempts int) time.Duration {
+	delay := c.Backoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= c.MaxBackoff {
+			return c.MaxBackoff
+		}
+	}
+
+	return delay
+}
+
+// outboxWaker wakes the outbox worker up once a new entry is enqueued
+type outboxWaker struct {
+	once sync.Once
+	ch   chan struct{}
+}
+
+// channel returns the wake up channel
+func (w *outboxWaker) channel() chan struct{} {
+	w.once.Do(func() {
+		w.ch = make(chan struct{}, 1)
+	})
+
+	return w.ch
+}
+
+// wake wakes the worker up, without blocking
+func (w *outboxWaker) wake() {
+	select {
+	case w.channel() <- struct{}{}:
+	default:
+	}
+}
+
+// enqueueReply writes the agent reply to the outbox, and posts a status message to be edited once it is delivered
+// it returns the status message (empty if it is not posted), and whether the reply is queued
+func (b *TelegramBot) enqueueReply(message *tgBotApi.Message, phone, text string,
+	media *m.Media) (tgBotApi.Message, bool) {
+	status, err := b.replyTo(message, fmt.Sprintf("%s message to [%s] is queued", emoji.Hourglass, phone))
+	if err != nil {
+		b.log.Warn(fmt.Sprintf("failed to send the outbox status message -> %s", err.Error()))
+	}
+
+	entry := &OutboxEntry{
+		Id:          fmt.Sprintf("%d:%d", message.Chat.ID, message.MessageID),
+		TgChatId:    message.Chat.ID,
+		TgMsgId:     message.MessageID,
+		StatusMsgId: status.MessageID,
+		Phone:       phone,
+		Message:     text,
+		Media:       media,
+		ReceivedAt:  message.Time().UTC(),
+		NextAttempt: time.Now().UTC(),
+	}
+	if message.From != nil {
+		entry.TgUserId = message.From.ID
+		entry.TgUsername = message.From.UserName
+	}
+
+	err = b.Outbox.Enqueue(entry)
+	if err != nil {
+		b.log.Error(fmt.Sprintf("failed to write the reply to the outbox -> %s", err.Error()))
+		b.audit(message, phone, text, AuditFailed, err)
+		b.updateOutboxStatus(entry, fmt.Sprintf("%s failed to queue the message to [%s]", emoji.CrossMark, phone))
+		return status, false
+	}
+
+	b.outboxWaker.wake()
+
+	return status, true
+}
+
+// RunOutbox delivers the queued agent replies until the context is cancelled
+func (b *TelegramBot) RunOutbox(ctx context.Context, cfg OutboxConfig) {
+	if b.Outbox == nil {
+		b.log.Warn("outbox is not configured")
+		return
+	}
+	if b.Messenger == nil {
+		b.log.Warn("messenger is not configured, the outbox is not delivered")
+		return
+	}
+	cfg = cfg.withDefaults()
+
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		b.deliverOutbox(ctx, cfg)
+
+		select {
+		case <-ctx.Done():
+			b.log.Info("stops delivering the outbox")
+			return
+		case <-ticker.C:
+		case <-b.outboxWaker.channel():
+		}
+	}
+}
+
+// deliverOutbox delivers the due entries, up to the batch size
+// each entry is claimed right before it is sent, so that the lease only has to cover a single sending
+func (b *TelegramBot) deliverOutbox(ctx context.Context, cfg OutboxConfig) {
+	for i := 0; i < cfg.BatchSize && ctx.Err() == nil; i++ {
+		entries, err := b.Outbox.Claim(time.Now().UTC(), cfg.Lease, 1)
+		if err != nil {
+			b.log.Warn(fmt.Sprintf("failed to claim the outbox entries -> %s", err.Error()))
+			return
+		}
+		if len(entries) == 0 {
+			return
+		}
+
+		b.deliverOutboxEntry(ctx, entries[0], cfg)
+	}
+}
+
+// deliverOutboxEntry sends the entry, and either completes it or schedules the next attempt
+func (b *TelegramBot) deliverOutboxEntry(ctx context.Context, entry *OutboxEntry, cfg OutboxConfig) {
+	entry.Attempts++
+
+	// the sending must not outlast the lease, otherwise another worker claims the entry again
+	sendCtx, cancel := context.WithTimeout(ctx, cfg.Lease)
+	result, err := b.Messenger.SendToWhatsapp(sendCtx, m.PostWhatsappMsg{
+		Phone:   ph.FromJIDUser(entry.Phone),
+		Message: entry.Message,
+		Media:   entry.Media,
+	})
+	cancel()
+	if err == nil {
+		b.trackDelivery(entry.TgChatId, entry.Phone, result.MsgId)
+		b.completeOutboxEntry(entry, AuditSent, nil)
+
+		statusText := fmt.Sprintf("%s %s", emoji.CheckMark, result.Message)
+		b.updateOutboxStatus(entry, statusText)
+		b.attachDeliveryStatus(result.MsgId, entry.StatusMsgId, statusText)
+
+		// records the agent reply, e.g. for the history
+		b.saveCorrelation(tgBotApi.Message{MessageID: entry.TgMsgId, Chat: &tgBotApi.Chat{ID: entry.TgChatId}},
+			&WhatsappMessage{
+				ChatJID:  types.NewJID(entry.Phone, types.DefaultUserServer).String(),
+				MsgId:    result.MsgId,
+				Phone:    entry.Phone,
+				Message:  entry.Message,
+				Outgoing: true,
+			})
+		return
+	}
+
+	entry.LastError = err.Error()
+	b.log.Warn(fmt.Sprintf("failed to deliver the outbox entry [%s] (attempt %d/%d) -> %s", entry.Id,
+		entry.Attempts, cfg.MaxAttempts, err.Error()))
+
+	// gives up on the last attempt, or if the gateway rejected the message (e.g. invalid recipient)
+	var gwErr *m.GatewayError
+	rejected := errors.As(err, &gwErr) && !gwErr.Retryable()
+	if rejected || entry.Attempts >= cfg.MaxAttempts {
+		b.completeOutboxEntry(entry, AuditFailed, err)
+		b.updateOutboxStatus(entry, fmt.Sprintf("%s failed to reply chat from recipient [%s] after %d attempt(s)",
+			emoji.CrossMark, entry.Phone, entry.Attempts))
+		return
+	}
+
+	entry.NextAttempt = time.Now().UTC().Add(cfg.backoff(entry.Attempts))
+	err = b.Outbox.Update(entry)
+	if err != nil {
+		b.log.Warn(fmt.Sprintf("failed to update the outbox entry [%s] -> %s", entry.Id, err.Error()))
+	}
+}
+
+// completeOutboxEntry removes the delivered (or given up) entry, and records it in the audit log
+func (b *TelegramBot) completeOutboxEntry(entry *OutboxEntry, result string, cause error) {
+	err := b.Outbox.Remove(entry.Id)
+	if err != nil {
+		b.log.Warn(fmt.Sprintf("failed to remove the outbox entry [%s] -> %s", entry.Id, err.Error()))
+	}
+
+	auditEntry := &AuditEntry{
+		TgChatId:   entry.TgChatId,
+		TgMsgId:    entry.TgMsgId,
+		TgUserId:   entry.TgUserId,
+		TgUsername: entry.TgUsername,
+		Phone:      entry.Phone,
+		Message:    entry.Message,
+		Result:     result,
+		ReceivedAt: entry.ReceivedAt,
+	}
+	if cause != nil {
+		auditEntry.Error = cause.Error()
+	}
+	b.saveAudit(auditEntry)
+}
+
+// updateOutboxStatus edits the status message of the entry
+func (b *TelegramBot) updateOutboxStatus(entry *OutboxEntry, text string) {
+	if entry.StatusMsgId == 0 {
+		return
+	}
+
+	_, err := b.send(entry.TgChatId, tgBotApi.NewEditMessageText(entry.TgChatId, entry.StatusMsgId, text))
+	if err != nil {
+		b.log.Warn(fmt.Sprintf("failed to update the outbox status message -> %s", err.Error()))
+	}
+}
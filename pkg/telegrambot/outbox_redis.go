@@ -0,0 +1,114 @@
+package telegrambot
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	goRedis "github.com/go-redis/redis"
+
+	"github.com/ardihikaru/go-modules/pkg/redis"
+)
+
+// redis keys of the outbox
+const (
+	outboxKey    = "tgbot:outbox"
+	outboxDueKey = "tgbot:outbox:due"
+)
+
+// claimOutboxScript atomically gets the due outbox entries, and postpones them by the lease
+var claimOutboxScript = goRedis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, id in ipairs(ids) do
+	redis.call('ZADD', KEYS[1], ARGV[3], id)
+end
+return ids
+`)
+
+// RedisOutboxStore stores the outbox in redis: the entries are kept in a hash,
+// and scheduled by a sorted set scored by their next attempt
+type RedisOutboxStore struct {
+	rdb *redis.Redis
+}
+
+// NewRedisOutboxStore builds the redis outbox store
+func NewRedisOutboxStore(rdb *redis.Redis) *RedisOutboxStore {
+	return &RedisOutboxStore{rdb: rdb}
+}
+
+// Enqueue stores the new outbox entry, due immediately
+func (s *RedisOutboxStore) Enqueue(e *OutboxEntry) error {
+	return s.saveOutbox(e)
+}
+
+// Claim gets the due outbox entries, and postpones them by the lease
+func (s *RedisOutboxStore) Claim(now time.Time, lease time.Duration, limit int) ([]*OutboxEntry, error) {
+	res, err := claimOutboxScript.Run(s.rdb.Client, []string{outboxDueKey},
+		now.UnixNano(), limit, now.Add(lease).UnixNano()).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	vals, _ := res.([]interface{})
+	if len(vals) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, 0, len(vals))
+	for _, val := range vals {
+		ids = append(ids, fmt.Sprint(val))
+	}
+
+	entryVals, err := s.rdb.Client.HMGet(outboxKey, ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*OutboxEntry, 0, len(entryVals))
+	for i, val := range entryVals {
+		str, ok := val.(string)
+		if !ok {
+			// the entry has been removed meanwhile
+			s.rdb.Client.ZRem(outboxDueKey, ids[i])
+			continue
+		}
+
+		var e OutboxEntry
+		err = json.Unmarshal([]byte(str), &e)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &e)
+	}
+
+	return entries, nil
+}
+
+// Update stores the outbox entry to be retried on its next attempt
+func (s *RedisOutboxStore) Update(e *OutboxEntry) error {
+	return s.saveOutbox(e)
+}
+
+// Remove removes the outbox entry
+func (s *RedisOutboxStore) Remove(id string) error {
+	pipe := s.rdb.Client.TxPipeline()
+	pipe.HDel(outboxKey, id)
+	pipe.ZRem(outboxDueKey, id)
+	_, err := pipe.Exec()
+
+	return err
+}
+
+// saveOutbox stores the outbox entry, scheduled on its next attempt
+func (s *RedisOutboxStore) saveOutbox(e *OutboxEntry) error {
+	p, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	pipe := s.rdb.Client.TxPipeline()
+	pipe.HSet(outboxKey, e.Id, p)
+	pipe.ZAdd(outboxDueKey, goRedis.Z{Score: float64(e.NextAttempt.UnixNano()), Member: e.Id})
+	_, err = pipe.Exec()
+
+	return err
+}
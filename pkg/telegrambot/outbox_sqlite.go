@@ -0,0 +1,110 @@
+package telegrambot
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// SQLiteOutboxStore stores the outbox in a SQLite database
+type SQLiteOutboxStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteOutboxStore builds the SQLite outbox store and prepares the table
+func NewSQLiteOutboxStore(dbName string) (*SQLiteOutboxStore, error) {
+	db, err := openSQLite(dbName,
+		`CREATE TABLE IF NOT EXISTS tg_outbox (
+		id           TEXT NOT NULL PRIMARY KEY,
+		next_attempt INTEGER NOT NULL,
+		entry        TEXT NOT NULL
+	)`)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQLiteOutboxStore{db: db}, nil
+}
+
+// Enqueue stores the new outbox entry, due immediately
+func (s *SQLiteOutboxStore) Enqueue(e *OutboxEntry) error {
+	return s.saveOutbox(e)
+}
+
+// Claim gets the due outbox entries, and postpones them by the lease
+func (s *SQLiteOutboxStore) Claim(now time.Time, lease time.Duration, limit int) ([]*OutboxEntry, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	rows, err := tx.Query(`SELECT entry FROM tg_outbox WHERE next_attempt <= ? ORDER BY next_attempt LIMIT ?`,
+		now.UnixNano(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*OutboxEntry
+	for rows.Next() {
+		var val string
+		err = rows.Scan(&val)
+		if err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+
+		var e OutboxEntry
+		err = json.Unmarshal([]byte(val), &e)
+		if err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		entries = append(entries, &e)
+	}
+	_ = rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		_, err = tx.Exec(`UPDATE tg_outbox SET next_attempt = ? WHERE id = ?`, now.Add(lease).UnixNano(), e.Id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, tx.Commit()
+}
+
+// Update stores the outbox entry to be retried on its next attempt
+func (s *SQLiteOutboxStore) Update(e *OutboxEntry) error {
+	return s.saveOutbox(e)
+}
+
+// Remove removes the outbox entry
+func (s *SQLiteOutboxStore) Remove(id string) error {
+	_, err := s.db.Exec(`DELETE FROM tg_outbox WHERE id = ?`, id)
+
+	return err
+}
+
+// saveOutbox stores the outbox entry, scheduled on its next attempt
+func (s *SQLiteOutboxStore) saveOutbox(e *OutboxEntry) error {
+	p, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`INSERT OR REPLACE INTO tg_outbox (id, next_attempt, entry) VALUES (?, ?, ?)`,
+		e.Id, e.NextAttempt.UnixNano(), string(p))
+
+	return err
+}
+
+// Close closes the database connection
+func (s *SQLiteOutboxStore) Close() error {
+	return s.db.Close()
+}
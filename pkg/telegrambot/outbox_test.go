@@ -0,0 +1,139 @@
+package telegrambot
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	m "github.com/ardihikaru/go-modules/pkg/messenger"
+)
+
+// hookSender calls the hook before recording each message
+type hookSender struct {
+	*m.RecordingSender
+	hook func(ctx context.Context, msg m.PostWhatsappMsg) error
+}
+
+func (s *hookSender) SendToWhatsapp(ctx context.Context, msg m.PostWhatsappMsg) (*m.SendResult, error) {
+	err := s.hook(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.RecordingSender.SendToWhatsapp(ctx, msg)
+}
+
+// newOutboxTestBot builds the bot with an outbox stored in a temporary SQLite database
+func newOutboxTestBot(t *testing.T, sender m.Sender, phones ...string) (*TelegramBot, *SQLiteOutboxStore) {
+	t.Helper()
+
+	store, err := NewSQLiteOutboxStore(filepath.Join(t.TempDir(), "outbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := newTestBot(t, newFakeTelegramAPI(t))
+	b.Messenger = sender
+	b.Outbox = store
+
+	now := time.Now().UTC()
+	for i, phone := range phones {
+		err = store.Enqueue(&OutboxEntry{
+			Id:          phone,
+			TgChatId:    testGroupChatId,
+			TgMsgId:     i + 1,
+			Phone:       phone,
+			Message:     "reply",
+			ReceivedAt:  now,
+			NextAttempt: now.Add(time.Duration(i) * time.Millisecond),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return b, store
+}
+
+func TestOutboxClaimsEachEntryBeforeSending(t *testing.T) {
+	var b *TelegramBot
+	var store *SQLiteOutboxStore
+	var unclaimed []int
+
+	sender := &hookSender{RecordingSender: &m.RecordingSender{}}
+	sender.hook = func(ctx context.Context, msg m.PostWhatsappMsg) error {
+		// the entries which are not sent yet must not be postponed by the lease, i.e. claimable by other workers
+		var due int
+		err := store.db.QueryRow(`SELECT COUNT(*) FROM tg_outbox WHERE next_attempt <= ?`,
+			time.Now().UTC().Add(time.Second).UnixNano()).Scan(&due)
+		unclaimed = append(unclaimed, due)
+
+		return err
+	}
+	b, store = newOutboxTestBot(t, sender, "62811", "62812", "62813")
+
+	b.deliverOutbox(context.Background(), OutboxConfig{}.withDefaults())
+
+	if sent := sender.Sent(); len(sent) != 3 || sent[0].Phone != "+62811" || sent[2].Phone != "+62813" {
+		t.Fatalf("expected the 3 entries to be sent in order, got %+v", sent)
+	}
+	if len(unclaimed) != 3 || unclaimed[0] != 2 || unclaimed[1] != 1 || unclaimed[2] != 0 {
+		t.Fatalf("expected the remaining entries to be unclaimed while sending, got %v", unclaimed)
+	}
+
+	entries, err := store.Claim(time.Now().UTC().Add(time.Hour), time.Minute, 10)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("expected the delivered entries to be removed, got %d, %v", len(entries), err)
+	}
+}
+
+func TestOutboxSendingIsBoundedByLease(t *testing.T) {
+	lease := 100 * time.Millisecond
+
+	sender := &hookSender{RecordingSender: &m.RecordingSender{}}
+	sender.hook = func(ctx context.Context, msg m.PostWhatsappMsg) error {
+		// a gateway which never answers
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	b, store := newOutboxTestBot(t, sender, "62811")
+
+	start := time.Now()
+	b.deliverOutbox(context.Background(), OutboxConfig{Lease: lease}.withDefaults())
+	if elapsed := time.Since(start); elapsed > 10*lease {
+		t.Fatalf("expected the sending to be cancelled after the lease, took %s", elapsed)
+	}
+
+	entries, err := store.Claim(time.Now().UTC().Add(time.Hour), time.Minute, 10)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected the entry to be rescheduled, got %d, %v", len(entries), err)
+	}
+	if entries[0].Attempts != 1 || entries[0].LastError != context.DeadlineExceeded.Error() {
+		t.Fatalf("unexpected entry %+v", entries[0])
+	}
+	if len(sender.Sent()) != 0 {
+		t.Fatal("the entry must not be recorded as sent")
+	}
+}
+
+func TestRunOutboxWithoutMessenger(t *testing.T) {
+	b, store := newOutboxTestBot(t, nil, "62811")
+	b.Messenger = nil
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.RunOutbox(context.Background(), OutboxConfig{})
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the outbox worker to return without the messenger")
+	}
+
+	entries, err := store.Claim(time.Now().UTC().Add(time.Hour), time.Minute, 10)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected the entry to be kept, got %d, %v", len(entries), err)
+	}
+}
@@ -0,0 +1,146 @@
+package telegrambot
+
+import (
+	"strings"
+
+	tgBotApi "github.com/matterbridge/telegram-bot-api/v6"
+)
+
+// Route maps the whatsapp messages to the designated telegram chat
+//
+// Each non-empty criterion must match (AND), and any value of a criterion may match (OR),
+// e.g. Sessions: ["628111"], Keywords: ["refund", "invoice"] only matches the messages
+// of session 628111 containing either "refund" or "invoice".
+type Route struct {
+	// Name identifies the route, e.g. in the logs
+	Name string
+
+	// ChatId is the target telegram chat
+	ChatId int64
+
+	// Sessions lists the phones of the whatsapp sessions
+	Sessions []string
+
+	// PhonePrefixes lists the prefixes of the sender phone, e.g. "62" or "+6281"
+	PhonePrefixes []string
+
+	// Keywords lists the case-insensitive keywords of the message text
+	Keywords []string
+
+	// Labels lists the case-insensitive labels of the whatsapp contact, resolved by watelebot.WhatsappBot.Labels
+	Labels []string
+}
+
+// Routing defines the rules to route the whatsapp messages to multiple telegram chats
+type Routing struct {
+	// Routes are evaluated in order, the first matched route is used
+	Routes []Route
+
+	// DefaultChatId is used if no route matches, zero uses the configured group
+	DefaultChatId int64
+}
+
+// match checks whether the whatsapp message matches the route
+func (r *Route) match(waMsg *WhatsappMessage) bool {
+	if len(r.Sessions) > 0 && !matchAny(r.Sessions, func(session string) bool {
+		return strings.TrimPrefix(session, "+") == strings.TrimPrefix(waMsg.Session, "+")
+	}) {
+		return false
+	}
+
+	if len(r.PhonePrefixes) > 0 && !matchAny(r.PhonePrefixes, func(prefix string) bool {
+		return strings.HasPrefix(strings.TrimPrefix(waMsg.Phone, "+"), strings.TrimPrefix(prefix, "+"))
+	}) {
+		return false
+	}
+
+	if len(r.Keywords) > 0 && !matchAny(r.Keywords, func(keyword string) bool {
+		return keyword != "" && strings.Contains(strings.ToLower(waMsg.Message), strings.ToLower(keyword))
+	}) {
+		return false
+	}
+
+	if len(r.Labels) > 0 && !matchAny(r.Labels, func(label string) bool {
+		for _, l := range waMsg.Labels {
+			if strings.EqualFold(l, label) {
+				return true
+			}
+		}
+		return false
+	}) {
+		return false
+	}
+
+	return true
+}
+
+// matchAny checks whether any of the values matches
+func matchAny(values []string, matchFn func(string) bool) bool {
+	for _, v := range values {
+		if matchFn(v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// chatFor resolves the telegram chat of the whatsapp message
+func (b *TelegramBot) chatFor(waMsg *WhatsappMessage) int64 {
+	if b.Routing == nil {
+		return b.groupChatId
+	}
+
+	for i := range b.Routing.Routes {
+		route := &b.Routing.Routes[i]
+		if route.ChatId != 0 && route.match(waMsg) {
+			return route.ChatId
+		}
+	}
+
+	if b.Routing.DefaultChatId != 0 {
+		return b.Routing.DefaultChatId
+	}
+
+	return b.groupChatId
+}
+
+// routedChats lists the configured group and the target chats of the routes
+func (b *TelegramBot) routedChats() []int64 {
+	var chats []int64
+	seen := make(map[int64]bool)
+	add := func(chatId int64) {
+		if chatId != 0 && !seen[chatId] {
+			seen[chatId] = true
+			chats = append(chats, chatId)
+		}
+	}
+
+	add(b.groupChatId)
+	if b.Routing != nil {
+		add(b.Routing.DefaultChatId)
+		for _, route := range b.Routing.Routes {
+			add(route.ChatId)
+		}
+	}
+
+	return chats
+}
+
+// isRoutedChat checks whether the chat is the configured group or any target chat of the routes
+func (b *TelegramBot) isRoutedChat(chat *tgBotApi.Chat) bool {
+	if b.isConfiguredGroup(chat) {
+		return true
+	}
+	if chat == nil {
+		return false
+	}
+
+	for _, chatId := range b.routedChats() {
+		if chat.ID == chatId {
+			return true
+		}
+	}
+
+	return false
+}
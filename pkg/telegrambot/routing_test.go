@@ -0,0 +1,28 @@
+package telegrambot
+
+import (
+	"testing"
+)
+
+func TestChatForLabels(t *testing.T) {
+	b := &TelegramBot{groupChatId: testGroupChatId}
+	b.Routing = &Routing{Routes: []Route{
+		{Name: "vip", ChatId: -1001, Labels: []string{"VIP"}},
+		{Name: "indonesia", ChatId: -1002, PhonePrefixes: []string{"+62"}},
+	}}
+
+	for _, tc := range []struct {
+		name   string
+		waMsg  *WhatsappMessage
+		chatId int64
+	}{
+		{"label", &WhatsappMessage{Phone: "6281234567890", Labels: []string{"reseller", "vip"}}, -1001},
+		{"other label", &WhatsappMessage{Phone: "6281234567890", Labels: []string{"reseller"}}, -1002},
+		{"no label", &WhatsappMessage{Phone: "6281234567890"}, -1002},
+		{"no route", &WhatsappMessage{Phone: "6591234567"}, testGroupChatId},
+	} {
+		if chatId := b.chatFor(tc.waMsg); chatId != tc.chatId {
+			t.Fatalf("%s: expected chat [%d], got [%d]", tc.name, tc.chatId, chatId)
+		}
+	}
+}
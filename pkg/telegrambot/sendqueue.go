@@ -0,0 +1,320 @@
+package telegrambot
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	tgBotApi "github.com/matterbridge/telegram-bot-api/v6"
+)
+
+// defaults of the telegram send limits, see https://core.telegram.org/bots/faq#my-bot-is-hitting-limits-how-do-i-avoid-this
+const (
+	defaultGlobalSendInterval = time.Second / 30
+	defaultChatSendInterval   = time.Second
+	defaultGroupSendInterval  = 3 * time.Second // 20 messages per minute
+	defaultMaxSendRetries     = 5
+)
+
+// maxMessageLength defines the maximum length of a telegram text message
+const maxMessageLength = 4096
+
+// SendLimits defines the send rate limits of the telegram bot, the zero values use the defaults
+type SendLimits struct {
+	// GlobalInterval is the minimum interval between any two messages of the bot
+	GlobalInterval time.Duration
+
+	// ChatInterval and GroupInterval are the minimum intervals between two messages of the same private chat
+	// and of the same group respectively
+	ChatInterval  time.Duration
+	GroupInterval time.Duration
+
+	// MaxRetries is the number of retries after telegram responded 429 (too many requests)
+	MaxRetries int
+}
+
+// withDefaults fills the zero values with the defaults
+func (l SendLimits) withDefaults() SendLimits {
+	if l.GlobalInterval <= 0 {
+		l.GlobalInterval = defaultGlobalSendInterval
+	}
+	if l.ChatInterval <= 0 {
+		l.ChatInterval = defaultChatSendInterval
+	}
+	if l.GroupInterval <= 0 {
+		l.GroupInterval = defaultGroupSendInterval
+	}
+	if l.MaxRetries <= 0 {
+		l.MaxRetries = defaultMaxSendRetries
+	}
+
+	return l
+}
+
+// interval returns the minimum interval between two messages of the chat, groups have negative IDs
+func (l SendLimits) interval(chatId int64) time.Duration {
+	if chatId < 0 {
+		return l.GroupInterval
+	}
+
+	return l.ChatInterval
+}
+
+// QueueDepth defines the number of messages waiting to be sent (including the ones being sent)
+type QueueDepth struct {
+	Total int           `json:"total"`
+	Chats map[int64]int `json:"chats"`
+
+	// Pending is the number of whatsapp messages and telegram updates waiting for the worker of their chat
+	Pending int `json:"pending"`
+}
+
+// chatQueue serializes the messages of a chat, so that they are sent in order
+type chatQueue struct {
+	mu sync.Mutex // held while a message of the chat is being sent
+
+	// guarded by sendQueue.mu
+	next    time.Time // the earliest time to send the next message
+	waiting int
+}
+
+// sendQueue throttles the messages of the bot per chat and globally, the zero value is ready to use
+type sendQueue struct {
+	mu         sync.Mutex
+	nextGlobal time.Time
+	chats      map[int64]*chatQueue
+	total      int
+}
+
+// enter queues a message of the chat, and returns the queue of the chat
+func (q *sendQueue) enter(chatId int64) *chatQueue {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.chats == nil {
+		q.chats = make(map[int64]*chatQueue)
+	}
+
+	cq, ok := q.chats[chatId]
+	if !ok {
+		// removes the idle chats, whose interval has elapsed
+		now := time.Now()
+		for id, idle := range q.chats {
+			if idle.waiting == 0 && !idle.next.After(now) {
+				delete(q.chats, id)
+			}
+		}
+
+		cq = &chatQueue{}
+		q.chats[chatId] = cq
+	}
+	cq.waiting++
+	q.total++
+
+	return cq
+}
+
+// leave removes the sent (or failed) message of the chat from the queue
+func (q *sendQueue) leave(cq *chatQueue) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	cq.waiting--
+	q.total--
+}
+
+// waitGlobal waits for the global slot of the next message
+func (q *sendQueue) waitGlobal(interval time.Duration) {
+	q.mu.Lock()
+	now := time.Now()
+	slot := now
+	if q.nextGlobal.After(slot) {
+		slot = q.nextGlobal
+	}
+	q.nextGlobal = slot.Add(interval)
+	q.mu.Unlock()
+
+	time.Sleep(slot.Sub(now))
+}
+
+// waitChat waits for the turn of the chat
+func (q *sendQueue) waitChat(cq *chatQueue) {
+	q.mu.Lock()
+	next := cq.next
+	q.mu.Unlock()
+
+	time.Sleep(time.Until(next))
+}
+
+// delayChat delays the next message of the chat
+func (q *sendQueue) delayChat(cq *chatQueue, delay time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	cq.next = time.Now().Add(delay)
+}
+
+// depth returns the number of messages waiting to be sent
+func (q *sendQueue) depth() QueueDepth {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	depth := QueueDepth{Total: q.total, Chats: make(map[int64]int)}
+	for chatId, cq := range q.chats {
+		if cq.waiting > 0 {
+			depth.Chats[chatId] = cq.waiting
+		}
+	}
+
+	return depth
+}
+
+// send sends the message to the chat through the send queue
+// it waits for the turn of the chat and the global slot, and retries after the `retry_after` of a 429 response
+func (b *TelegramBot) send(chatId int64, c tgBotApi.Chattable) (tgBotApi.Message, error) {
+	limits := SendLimits{}
+	if b.SendLimits != nil {
+		limits = *b.SendLimits
+	}
+	limits = limits.withDefaults()
+
+	cq := b.sendQueue.enter(chatId)
+	defer b.sendQueue.leave(cq)
+
+	// the chat lock is held until the message is sent, including the retries, to keep the order
+	cq.mu.Lock()
+	defer cq.mu.Unlock()
+
+	for retry := 0; ; retry++ {
+		b.sendQueue.waitChat(cq)
+		b.sendQueue.waitGlobal(limits.GlobalInterval)
+
+		sent, err := b.Bot.Send(c)
+		b.sendQueue.delayChat(cq, limits.interval(chatId))
+
+		var tgErr *tgBotApi.Error
+		if !errors.As(err, &tgErr) || tgErr.RetryAfter <= 0 || retry >= limits.MaxRetries {
+			return sent, err
+		}
+
+		retryAfter := time.Duration(tgErr.RetryAfter) * time.Second
+		b.log.Warn(fmt.Sprintf("telegram throttled chat [%d], retries after %s (retry %d/%d)", chatId, retryAfter,
+			retry+1, limits.MaxRetries))
+		b.sendQueue.delayChat(cq, retryAfter)
+	}
+}
+
+// QueueDepth returns the number of telegram messages waiting to be sent
+func (b *TelegramBot) QueueDepth() QueueDepth {
+	depth := b.sendQueue.depth()
+	depth.Pending = b.chatWorkers.pending()
+
+	return depth
+}
+
+// chatWorkers runs the jobs of each chat in order on a goroutine of the chat, so that the callers
+// (e.g. the telegram update loop and the whatsapp event handler) only queue the jobs, while the workers wait
+// for the send limits; the zero value is ready to use
+type chatWorkers struct {
+	mu    sync.Mutex
+	jobs  map[int64][]func() // the chats with a running worker, and their queued jobs
+	total int                // the queued jobs, including the running ones
+}
+
+// runInChat queues the job of the chat, and starts the worker of the chat if it is not running
+func (b *TelegramBot) runInChat(chatId int64, job func()) {
+	w := &b.chatWorkers
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.jobs == nil {
+		w.jobs = make(map[int64][]func())
+	}
+
+	jobs, running := w.jobs[chatId]
+	w.jobs[chatId] = append(jobs, job)
+	w.total++
+	if !running {
+		go b.workChat(chatId)
+	}
+}
+
+// workChat runs the queued jobs of the chat in order, and stops once there is no job left
+func (b *TelegramBot) workChat(chatId int64) {
+	w := &b.chatWorkers
+	for {
+		w.mu.Lock()
+		jobs := w.jobs[chatId]
+		if len(jobs) == 0 {
+			delete(w.jobs, chatId)
+			w.mu.Unlock()
+			return
+		}
+		job := jobs[0]
+		jobs[0] = nil
+		w.jobs[chatId] = jobs[1:]
+		w.mu.Unlock()
+
+		b.safeRunJob(chatId, job)
+
+		w.mu.Lock()
+		w.total--
+		w.mu.Unlock()
+	}
+}
+
+// safeRunJob runs the job of the chat, and recovers from any panic so that the worker keeps running
+func (b *TelegramBot) safeRunJob(chatId int64, job func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			b.log.Error(fmt.Sprintf("recovered from a panic on a job of chat [%d] -> %v\n%s", chatId, r,
+				debug.Stack()))
+		}
+	}()
+
+	job()
+}
+
+// pending returns the number of the queued jobs
+func (w *chatWorkers) pending() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.total
+}
+
+// splitText splits the text into chunks of the designated length, see cutText
+func splitText(text string, maxLength int) []string {
+	var chunks []string
+	for textLength(text) > maxLength {
+		chunk := cutText(text, maxLength)
+		if chunk == "" {
+			break
+		}
+		chunks = append(chunks, chunk)
+		text = text[len(chunk):]
+	}
+
+	return append(chunks, text)
+}
+
+// cutText returns the leading part of the text within the designated length (see textLength),
+// preferably cut after a line break or a space
+func cutText(text string, maxLength int) string {
+	chunk := truncateText(text, maxLength)
+	if len(chunk) == len(text) {
+		return chunk
+	}
+
+	if i := strings.LastIndex(chunk, "\n"); i > 0 {
+		return chunk[:i+1]
+	}
+	if i := strings.LastIndex(chunk, " "); i > 0 {
+		return chunk[:i+1]
+	}
+
+	return chunk
+}
@@ -0,0 +1,150 @@
+package telegrambot
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgBotApi "github.com/matterbridge/telegram-bot-api/v6"
+)
+
+func TestSplitTextCountsUTF16(t *testing.T) {
+	// each emoji is 2 UTF-16 code units, but a single rune
+	text := strings.Repeat("😀 ", 3000)
+
+	chunks := splitText(text, maxMessageLength)
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if length := textLength(chunk); length > maxMessageLength {
+			t.Fatalf("chunk %d is %d code units long", i, length)
+		}
+		if i < len(chunks)-1 && !strings.HasSuffix(chunk, " ") {
+			t.Fatalf("chunk %d is not cut after a space", i)
+		}
+	}
+	if strings.Join(chunks, "") != text {
+		t.Fatal("the chunks must add up to the text")
+	}
+}
+
+func TestSplitTextPrefersLineBreaks(t *testing.T) {
+	text := strings.Repeat("a", 3000) + "\n" + strings.Repeat("b c ", 500)
+
+	chunks := splitText(text, maxMessageLength)
+	if len(chunks) != 2 || chunks[0] != strings.Repeat("a", 3000)+"\n" {
+		t.Fatalf("expected the text to be cut after the line break, got %d chunks", len(chunks))
+	}
+
+	if chunks := splitText("short", maxMessageLength); len(chunks) != 1 || chunks[0] != "short" {
+		t.Fatalf("expected a single chunk, got %q", chunks)
+	}
+	if got := truncateText("😀😀", 3); got != "😀" {
+		t.Fatalf("expected the emoji not to be split, got %q", got)
+	}
+}
+
+func TestSendRetriesAfterTooManyRequests(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	b := newTestBot(t, api)
+
+	throttled := false
+	api.mu.Lock()
+	api.override = func(w http.ResponseWriter, method string) bool {
+		// called with api.mu unlocked, by a single request at a time
+		if throttled || method != "sendMessage" {
+			return false
+		}
+		throttled = true
+
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = fmt.Fprint(w, `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1",`+
+			`"parameters":{"retry_after":1}}`)
+		return true
+	}
+	api.mu.Unlock()
+
+	start := time.Now()
+	sent, err := b.send(testGroupChatId, tgBotApi.NewMessage(testGroupChatId, "hello"))
+	if err != nil {
+		t.Fatalf("expected the message to be sent after the retry, got %v", err)
+	}
+	if sent.MessageID != 10 {
+		t.Fatalf("unexpected sent message %+v", sent)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("expected the retry after 1s, retried after %s", elapsed)
+	}
+	if depth := b.QueueDepth(); depth.Total != 0 || len(depth.Chats) != 0 {
+		t.Fatalf("expected an empty queue, got %+v", depth)
+	}
+}
+
+func TestSendQueueConcurrentChats(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	b := newTestBot(t, api)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func(chatId int64) {
+			defer wg.Done()
+
+			_, err := b.send(chatId, tgBotApi.NewMessage(chatId, "hello"))
+			if err != nil {
+				t.Errorf("failed to send to chat [%d]: %v", chatId, err)
+			}
+			_ = b.QueueDepth()
+		}(int64(i%3 + 1))
+	}
+	wg.Wait()
+
+	if calls := api.called("sendMessage"); len(calls) != 30 {
+		t.Fatalf("expected 30 messages, got %d", len(calls))
+	}
+	if depth := b.QueueDepth(); depth.Total != 0 {
+		t.Fatalf("expected an empty queue, got %+v", depth)
+	}
+}
+
+func TestForwardMsgOnlyQueues(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	b := newTestBot(t, api)
+
+	block := make(chan struct{})
+	api.mu.Lock()
+	api.block = block
+	api.mu.Unlock()
+
+	start := time.Now()
+	for i := 1; i <= 3; i++ {
+		b.ForwardMsg(&WhatsappMessage{Phone: "628123", Name: "John", Message: fmt.Sprintf("message %d", i),
+			Timestamp: time.Now()})
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("expected the caller not to wait for the send, waited %s", elapsed)
+	}
+	if depth := b.QueueDepth(); depth.Pending != 3 {
+		t.Fatalf("expected 3 pending messages, got %+v", depth)
+	}
+
+	close(block)
+	calls := api.waitCalled(t, "sendMessage", 3)
+	for i, call := range calls {
+		if !strings.Contains(call["text"], fmt.Sprintf("message %d", i+1)) {
+			t.Fatalf("expected the messages in order, got %q at %d", call["text"], i)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for b.QueueDepth().Pending != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected no pending message, got %+v", b.QueueDepth())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -1,31 +1,103 @@
 package telegrambot
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"strings"
+	"runtime/debug"
+	"sync"
 	"time"
 
 	tgBotApi "github.com/matterbridge/telegram-bot-api/v6"
+	"go.mau.fi/whatsmeow/types"
 
 	"github.com/ardihikaru/go-modules/pkg/enums/emoji"
 	"github.com/ardihikaru/go-modules/pkg/enums/loglevel"
 	"github.com/ardihikaru/go-modules/pkg/logger"
 	m "github.com/ardihikaru/go-modules/pkg/messenger"
+	ph "github.com/ardihikaru/go-modules/pkg/utils/phone"
 )
 
+// errNoConversation is returned if the replied bot message is not correlated with any whatsapp conversation
+var errNoConversation = errors.New("no conversation found")
+
 type TelegramBot struct {
-	Messenger   *m.Messenger
-	Bot         *tgBotApi.BotAPI
+	// Messenger sends the agent replies to whatsapp, either over HTTP (messenger.Messenger)
+	// or in-process (wawebhook.LocalSender)
+	Messenger m.Sender
+	Bot       *tgBotApi.BotAPI
+
+	// Correlations stores the mapping between the posted telegram messages and their whatsapp origin
+	// if it is nil, the whatsapp phone is extracted from the header of the posted message instead
+	Correlations CorrelationStore
+
+	// Topics enables the forum topic mode if exists: the group must be a forum supergroup,
+	// and each whatsapp contact is posted into its own topic
+	Topics TopicStore
+
+	// Routing routes the whatsapp messages to multiple telegram chats if exists,
+	// otherwise every message is posted to the configured group
+	Routing *Routing
+
+	// Template defines the layout of the posted whatsapp messages if exists,
+	// otherwise the legacy plain text layout of buildTelegramMessage is used
+	Template *MessageTemplate
+
+	// Agents restricts who is allowed to reply to the whatsapp contacts if exists,
+	// otherwise every member of the configured chats is allowed
+	Agents *AgentPolicy
+
+	// Audit records every bridged reply if exists
+	Audit AuditStore
+
+	// Outbox queues the agent replies if exists, they are delivered by RunOutbox
+	// so that the replies are not lost while the whatsapp gateway is down
+	Outbox OutboxStore
+
+	// Deliveries tracks the delivery status of the agent replies if exists, the receipts reported by the gateway
+	// (see HandleReceipt) are reflected on the confirmation message
+	Deliveries DeliveryStore
+
+	// Tickets enables the conversation assignment if exists: each whatsapp conversation opens a ticket,
+	// which is assigned to an agent by /claim
+	Tickets TicketStore
+
+	// BlockUnassignedReplies blocks the replies of the agents other than the ticket assignee,
+	// otherwise they are sent with a warning
+	BlockUnassignedReplies bool
+
+	// SendLimits overrides the default telegram send limits if exists, see SendLimits
+	SendLimits *SendLimits
+
 	updates     tgBotApi.UpdatesChannel
 	log         *logger.Logger
 	groupChatId int64
 	groupTitle  string
+	outboxWaker outboxWaker
+	sendQueue   sendQueue
+	chatWorkers chatWorkers
+
+	webhookOnce    sync.Once
+	webhookUpdates chan tgBotApi.Update
+	webhookDedup   updateDedup
+
+	// webhookMu guards the secret token and the shutdown of the webhook, both read by the webhook requests
+	webhookMu     sync.RWMutex
+	webhookSecret string
+	webhookClosed bool
 }
 
 // Connect initializes telegram bot via API
 func Connect(log *logger.Logger, token, logLevel string, groupTitle *string, groupChatId *int64,
-	messenger *m.Messenger) (*TelegramBot, error) {
-	bot, err := tgBotApi.NewBotAPI(token)
+	messenger m.Sender) (*TelegramBot, error) {
+	return ConnectWithEndpoint(log, token, tgBotApi.APIEndpoint, logLevel, groupTitle, groupChatId, messenger)
+}
+
+// ConnectWithEndpoint initializes telegram bot via the designated API endpoint (e.g. a local bot API server)
+// the endpoint is formatted as `<host>/bot%s/%s`, see tgBotApi.APIEndpoint
+func ConnectWithEndpoint(log *logger.Logger, token, apiEndpoint, logLevel string, groupTitle *string,
+	groupChatId *int64, messenger m.Sender) (*TelegramBot, error) {
+	bot, err := tgBotApi.NewBotAPIWithAPIEndpoint(token, apiEndpoint)
 	if err != nil {
 		return nil, err
 	}
@@ -60,100 +132,384 @@ func (b *TelegramBot) Init() {
 	u.Timeout = 60
 
 	b.updates = b.Bot.GetUpdatesChan(u)
-}
 
-// Run starts subscribing messages
-func (b *TelegramBot) Run() {
-	for update := range b.updates {
-		if update.Message != nil {
-			var err error
+	b.initCommands()
+}
 
-			// only response message that has originated message
-			replyToMsg := update.Message.ReplyToMessage
-			if replyToMsg == nil {
-				continue
+// Run starts subscribing messages until the context is cancelled
+// a failure (or a panic) on processing an update does not stop the subscription
+func (b *TelegramBot) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			b.log.Info("stops subscribing telegram messages")
+			b.Bot.StopReceivingUpdates()
+			return
+		case update, ok := <-b.updates:
+			if !ok {
+				b.log.Warn("telegram updates channel has been closed")
+				return
 			}
+			b.dispatchUpdate(update)
+		}
+	}
+}
 
-			// only response message that's originated from the Bot
-			repliedMsgOwnerAsBot := replyToMsg.From.IsBot
-			if !repliedMsgOwnerAsBot {
-				continue
-			}
-			repliedMsg := cleanMsg(replyToMsg.Text)
+// dispatchUpdate queues the update to the worker of its chat, so that the updates of a chat are processed in order
+// without blocking the updates of the other chats
+func (b *TelegramBot) dispatchUpdate(update tgBotApi.Update) {
+	if update.Message == nil {
+		return
+	}
 
-			// at least 5 slices is a considered as a valid message
-			repliedMsgList := strings.Split(repliedMsg, "|")
-			if len(repliedMsgList) < 5 {
-				continue
-			}
+	b.runInChat(update.Message.Chat.ID, func() {
+		b.safeHandleUpdate(update)
+	})
+}
 
-			// extracts important information
-			phone := repliedMsgList[2]
+// safeHandleUpdate processes the captured telegram update, and recovers from any panic
+func (b *TelegramBot) safeHandleUpdate(update tgBotApi.Update) {
+	defer func() {
+		if r := recover(); r != nil {
+			b.log.Error(fmt.Sprintf("recovered from a panic on processing update [%d] -> %v\n%s",
+				update.UpdateID, r, debug.Stack()))
+		}
+	}()
 
-			// identifies the source of message
-			msgType := update.Message.Chat.Type    // e.g. "group"
-			groupName := update.Message.Chat.Title // e.g. "Chats CS SatuMedis PROD"
-			if msgType != "group" && groupName != b.groupTitle {
-				b.log.Debug("found ignorable messages. ignore captured message")
-				continue
-			}
+	b.handleUpdate(update)
+}
 
-			// process sending message to the Messenger
-			sent, msgToReply, err := b.Messenger.SendMsgToWhatsapp(phone, update.Message.Text)
-			if !sent {
-				msgToReply = fmt.Sprintf("failed to reply chat from recipient [%s]", phone)
-				return
-			}
+// handleUpdate processes the captured telegram update, either from the long polling or from the webhook
+func (b *TelegramBot) handleUpdate(update tgBotApi.Update) {
+	if update.Message == nil {
+		return
+	}
+	// agent commands (e.g. /send, /status)
+	if b.handleCommand(update.Message) {
+		return
+	}
 
-			msg := tgBotApi.NewMessage(update.Message.Chat.ID, msgToReply)
-			msg.ReplyToMessageID = update.Message.MessageID
+	// extracts important information
+	phone, ok, err := b.resolveRecipient(update.Message)
+	if !ok && err == nil {
+		return
+	}
 
-			err = send(b.Bot, msg)
-			if err != nil {
-				b.log.Warn(fmt.Sprintf("failed to send the message -> %s", err.Error()))
-				return
-			}
+	// only the configured group and the target chats of the routing rules are allowed to reply
+	if !b.isRoutedChat(update.Message.Chat) {
+		b.log.Debug("found ignorable messages. ignore captured message")
+		return
+	}
+
+	// the replied bot message is not resolved, the agent is told rather than the reply being dropped silently
+	if err != nil {
+		b.notifyFailure(update.Message, err.Error())
+		return
+	}
+
+	// the caption of the media sent by the agent (if any) is sent as the message
+	text := update.Message.Text
+	media, fileId := replyMedia(update.Message)
+	if media != nil {
+		text = update.Message.Caption
+	}
+
+	// ignores messages without any content (e.g. service messages, stickers)
+	if text == "" && media == nil {
+		return
+	}
+
+	// only the authorized agents are allowed to reply
+	if !b.authorizeAgent(update.Message) {
+		b.log.Warn(fmt.Sprintf("rejected an unauthorized reply to [%s]", phone))
+		b.audit(update.Message, phone, text, AuditDenied, nil)
+		b.notifyFailure(update.Message, "you are not allowed to reply to the WhatsApp contacts")
+		return
+	}
+
+	// only the ticket assignee is expected to reply
+	if !b.checkAssignee(update.Message, phone) {
+		b.audit(update.Message, phone, text, AuditDenied, nil)
+		return
+	}
+
+	// downloads the media only once the reply is allowed
+	if media != nil {
+		err = b.downloadMedia(fileId, media)
+		if err != nil {
+			b.log.Warn(fmt.Sprintf("failed to download the telegram media -> %s", err.Error()))
+			b.notifyFailure(update.Message, fmt.Sprintf("failed to download the media for recipient [%s]", phone))
+			return
 		}
 	}
-}
 
-// cleanMsg removes unexpected strings
-func cleanMsg(msg string) string {
-	msg = strings.Replace(msg, "SasaBot|", "", len(msg))
-	msg = strings.Replace(msg, "MESSAGE_ID:", "", len(msg))
-	msg = strings.Replace(msg, "PHONE:", "", len(msg))
-	msg = strings.Replace(msg, "FROM:", "", len(msg))
-	msg = strings.Replace(msg, fmt.Sprintf(" %s ", emoji.CheckMark), "", len(msg))
-	msg = strings.Replace(msg, "MESSAGE:", "", len(msg))
-	msg = strings.Replace(msg, "\n", "", len(msg))
+	// the outbox worker delivers the reply, if enabled
+	if b.Outbox != nil {
+		b.enqueueReply(update.Message, phone, text, media)
+		return
+	}
+
+	if b.Messenger == nil {
+		b.notifyFailure(update.Message, noMessengerReply)
+		return
+	}
+
+	// process sending message to the Messenger
+	result, err := b.Messenger.SendToWhatsapp(context.Background(), m.PostWhatsappMsg{
+		Phone:   ph.FromJIDUser(phone),
+		Message: text,
+		Media:   media,
+	})
+	if err != nil {
+		b.log.Warn(fmt.Sprintf("failed to send the message to [%s] -> %s", phone, err.Error()))
+		b.audit(update.Message, phone, text, AuditFailed, err)
+		b.notifyFailure(update.Message, fmt.Sprintf("failed to reply chat from recipient [%s]", phone))
+		return
+	}
+
+	b.audit(update.Message, phone, text, AuditSent, nil)
+	b.trackDelivery(update.Message.Chat.ID, phone, result.MsgId)
+
+	// records the agent reply, e.g. for the history
+	b.saveCorrelation(*update.Message, &WhatsappMessage{
+		ChatJID:  types.NewJID(phone, types.DefaultUserServer).String(),
+		MsgId:    result.MsgId,
+		Phone:    phone,
+		Message:  text,
+		Outgoing: true,
+	})
 
-	return msg
+	confirmation, err := b.replyTo(update.Message, result.Message)
+	if err != nil {
+		b.log.Warn(fmt.Sprintf("failed to send the message -> %s", err.Error()))
+		return
+	}
+
+	// the confirmation is edited once the delivery receipts arrive
+	b.attachDeliveryStatus(result.MsgId, confirmation.MessageID, result.Message)
 }
 
-// send sends a text message
-func send(bot *tgBotApi.BotAPI, msg tgBotApi.MessageConfig) error {
-	_, err := bot.Send(msg)
+// notifyFailure posts a visible failure notice as a reply to the agent message
+func (b *TelegramBot) notifyFailure(message *tgBotApi.Message, notice string) {
+	_, err := b.replyTo(message, fmt.Sprintf("%s %s", emoji.CrossMark, notice))
 	if err != nil {
-		return err
+		b.log.Warn(fmt.Sprintf("failed to send the failure notice -> %s", err.Error()))
 	}
+}
 
-	return nil
+// resolveRecipient resolves the whatsapp phone of the captured telegram message
+// on forum topic mode, any message typed in a topic is sent to its whatsapp contact,
+// otherwise only a reply to the bot's message is sent
+// it returns false without any error if the message is not meant for whatsapp, e.g. a chat between the agents
+func (b *TelegramBot) resolveRecipient(message *tgBotApi.Message) (string, bool, error) {
+	if phone, ok := b.resolveTopicPhone(message); ok {
+		return phone, true, nil
+	}
+
+	// only response message that has originated message
+	replyToMsg := message.ReplyToMessage
+	if replyToMsg == nil {
+		return "", false, nil
+	}
+
+	// only response message that's originated from the Bot
+	repliedMsgOwnerAsBot := replyToMsg.From != nil && replyToMsg.From.IsBot
+	if !repliedMsgOwnerAsBot {
+		return "", false, nil
+	}
+
+	phone, ok := b.resolvePhone(replyToMsg)
+	if !ok {
+		return "", false, errNoConversation
+	}
+
+	return phone, true, nil
+}
+
+// resolvePhone resolves the whatsapp phone of the replied telegram message
+// the correlation store is used if exists, otherwise the phone is extracted from the header rendered by the bot,
+// never from the customer message; the header is not used as a fallback of the correlation store
+func (b *TelegramBot) resolvePhone(replyToMsg *tgBotApi.Message) (string, bool) {
+	if b.Correlations != nil {
+		corr, err := b.Correlations.Get(replyToMsg.Chat.ID, replyToMsg.MessageID)
+		if err != nil {
+			if !errors.Is(err, ErrCorrelationNotFound) {
+				b.log.Warn(fmt.Sprintf("failed to get the message correlation -> %s", err.Error()))
+			}
+			return "", false
+		}
+
+		return corr.Phone, true
+	}
+
+	// media messages carry the message header in the caption
+	text := replyToMsg.Text
+	if text == "" {
+		text = replyToMsg.Caption
+	}
+
+	if b.Template != nil {
+		return b.Template.parsePhone(text)
+	}
+
+	return legacyTemplate.parsePhone(text)
+}
+
+// replyTo sends a text message as a reply to the designated message (in the same forum topic, if any)
+func (b *TelegramBot) replyTo(message *tgBotApi.Message, text string) (tgBotApi.Message, error) {
+	msg := tgBotApi.NewMessage(message.Chat.ID, text)
+	msg.ReplyToMessageID = message.MessageID
+	if message.IsTopicMessage {
+		msg.MessageThreadID = message.MessageThreadID
+	}
+
+	return b.send(message.Chat.ID, msg)
+}
+
+// WhatsappMessage defines the whatsapp message to forward to telegram
+type WhatsappMessage struct {
+	Session   string // the phone owner of the whatsapp session
+	ChatJID   string
+	MsgId     string
+	Phone     string
+	Name      string
+	Message   string // the text, or the caption of the media
+	Timestamp time.Time
+	Media     *Media   // nil if it is a text message
+	Outgoing  bool     // true if it is sent by an agent to whatsapp
+	Labels    []string // the labels of the whatsapp contact, matched by the routing rules
 }
 
 // SendTextMsg sends messages to the telegram bot
 func (b *TelegramBot) SendTextMsg(ts time.Time, phone, msgId, name, msg string) {
+	b.ForwardMsg(&WhatsappMessage{
+		ChatJID:   types.NewJID(phone, types.DefaultUserServer).String(),
+		MsgId:     msgId,
+		Phone:     phone,
+		Name:      name,
+		Message:   msg,
+		Timestamp: ts,
+	})
+}
+
+// ForwardMsg forwards the whatsapp message to the telegram group and records its correlation
+// the message is queued to the worker of the target chat, so that the caller does not wait for the send limits,
+// and the failures are logged
+func (b *TelegramBot) ForwardMsg(waMsg *WhatsappMessage) {
+	// resolves the target chat based on the routing rules
+	chatId := b.chatFor(waMsg)
+
+	b.runInChat(chatId, func() {
+		_ = b.forwardMsg(chatId, waMsg)
+	})
+}
+
+// forwardMsg sends the whatsapp message to the chat, and records its correlation
+func (b *TelegramBot) forwardMsg(chatId int64, waMsg *WhatsappMessage) error {
 	// builds telegram message content
-	msgTemplate := buildTelegramMessage(ts, msgId, phone, name, msg)
+	msgTemplate, parseMode, parts := b.renderMessage(waMsg)
+
+	// on forum topic mode, posts the message into the topic of the whatsapp contact
+	threadId := 0
+	if b.Topics != nil {
+		topic, err := b.topicFor(chatId, waMsg)
+		if err != nil {
+			b.log.Error(fmt.Sprintf("failed to get the forum topic of [%s] -> %s", waMsg.Phone, err.Error()))
+			return err
+		}
+		threadId = topic.ThreadId
+	}
 
 	// sends the message
-	tgMessage := tgBotApi.NewMessage(b.groupChatId, msgTemplate)
-	_, err := b.Bot.Send(tgMessage)
+	var sent tgBotApi.Message
+	var err error
+	if waMsg.Media != nil {
+		sent, err = b.sendMedia(chatId, threadId, msgTemplate, parseMode, waMsg.Media)
+	} else {
+		tgMessage := tgBotApi.NewMessage(chatId, msgTemplate)
+		tgMessage.MessageThreadID = threadId
+		tgMessage.ParseMode = parseMode
+		sent, err = b.send(chatId, tgMessage)
+	}
 	if err != nil {
 		b.log.Error(fmt.Sprintf("sending Telegram message failed -> %s", err.Error()))
+		return err
+	}
+
+	// the remaining parts of a long text reply to the first one, which is the one agents reply to
+	for i, part := range parts {
+		partMsg := tgBotApi.NewMessage(chatId, part)
+		partMsg.MessageThreadID = threadId
+		partMsg.ReplyToMessageID = sent.MessageID
+		_, err = b.send(chatId, partMsg)
+		if err != nil {
+			b.log.Warn(fmt.Sprintf("failed to send the part %d/%d of the message of [%s] -> %s", i+2,
+				len(parts)+1, waMsg.Phone, err.Error()))
+		}
+	}
+
+	b.saveCorrelation(sent, waMsg)
+	b.openTicket(chatId, waMsg)
+
+	return nil
+}
+
+// renderMessage renders the whatsapp message with the configured template, and returns the parse mode
+// a text exceeding the telegram limit is split, the remaining plain text parts are returned as well
+func (b *TelegramBot) renderMessage(waMsg *WhatsappMessage) (string, string, []string) {
+	if b.Template == nil {
+		text := buildTelegramMessage(waMsg.Timestamp, waMsg.MsgId, waMsg.Phone, waMsg.Name, waMsg.Message)
+		if waMsg.Media != nil {
+			return text, "", nil
+		}
+
+		parts := splitText(text, maxMessageLength)
+		return parts[0], "", parts[1:]
+	}
+
+	// the message header is the caption of the media
+	if waMsg.Media != nil {
+		return b.Template.render(waMsg, maxCaptionLength), b.Template.ParseMode, nil
+	}
+
+	// the plain header is as long as the parsed header, the first part of the message follows it
+	headerLength := textLength(b.Template.renderWith("", waMsg, ""))
+	if headerLength+textLength(waMsg.Message) <= maxMessageLength {
+		return b.Template.render(waMsg, 0), b.Template.ParseMode, nil
+	}
+
+	first := cutText(waMsg.Message, maxMessageLength-headerLength)
+	text := b.Template.renderWith(b.Template.ParseMode, waMsg, first)
+
+	return text, b.Template.ParseMode, splitText(waMsg.Message[len(first):], maxMessageLength)
+}
+
+// saveCorrelation records the correlation of the posted telegram message, if the store exists
+func (b *TelegramBot) saveCorrelation(sent tgBotApi.Message, waMsg *WhatsappMessage) {
+	if b.Correlations == nil {
 		return
 	}
+
+	err := b.Correlations.Save(&Correlation{
+		TgChatId:  sent.Chat.ID,
+		TgMsgId:   sent.MessageID,
+		WaSession: waMsg.Session,
+		WaChatJID: waMsg.ChatJID,
+		WaMsgId:   waMsg.MsgId,
+		Phone:     waMsg.Phone,
+		Name:      waMsg.Name,
+		Message:   waMsg.Message,
+		Outgoing:  waMsg.Outgoing,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		b.log.Warn(fmt.Sprintf("failed to store the message correlation -> %s", err.Error()))
+	}
 }
 
+// legacyTemplate parses the header of the messages built by buildTelegramMessage
+var legacyTemplate = DefaultMessageTemplate("SasaBot")
+
 // buildTelegramMessage generates telegram formatted message with a predefined message template
 func buildTelegramMessage(ts time.Time, msgId, phone, name, msg string) string {
 	return fmt.Sprintf("SasaBot|%s|\n"+
@@ -0,0 +1,122 @@
+package telegrambot
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tgBotApi "github.com/matterbridge/telegram-bot-api/v6"
+
+	"github.com/ardihikaru/go-modules/pkg/logger"
+	m "github.com/ardihikaru/go-modules/pkg/messenger"
+)
+
+// replyUpdate builds the update of an agent reply to the bot message, whose text looks like a legacy message
+func replyUpdate(text string) tgBotApi.Update {
+	group := &tgBotApi.Chat{ID: testGroupChatId, Type: "group", Title: "agents"}
+
+	return tgBotApi.Update{
+		UpdateID: 1,
+		Message: &tgBotApi.Message{
+			MessageID: 6,
+			From:      &tgBotApi.User{ID: 7, FirstName: "agent"},
+			Chat:      group,
+			Text:      text,
+			ReplyToMessage: &tgBotApi.Message{
+				MessageID: 5,
+				From:      &tgBotApi.User{ID: 1, IsBot: true, FirstName: "bot"},
+				Chat:      group,
+				Text:      "SasaBot|\nMESSAGE_ID: 1|\nPHONE: 6289999999999|\nFROM: spoofed|\nMESSAGE: hi",
+			},
+		},
+	}
+}
+
+func TestResolvePhoneOnlyFromCorrelation(t *testing.T) {
+	log, err := logger.New("error", "console")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewSQLiteCorrelationStore(filepath.Join(t.TempDir(), "correlations.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &TelegramBot{log: log, Correlations: store}
+
+	// a bot message whose text looks like a legacy message, e.g. a customer text quoted by the bot
+	replied := replyUpdate("hello").Message.ReplyToMessage
+	if phone, ok := b.resolvePhone(replied); ok {
+		t.Fatalf("the phone must not be parsed from the message text, got %s", phone)
+	}
+
+	err = store.Save(&Correlation{TgChatId: testGroupChatId, TgMsgId: replied.MessageID, Phone: "62811"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if phone, ok := b.resolvePhone(replied); !ok || phone != "62811" {
+		t.Fatalf("expected the correlated phone, got %s, %t", phone, ok)
+	}
+}
+
+func TestReplyWithoutConversation(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	b := newTestBot(t, api)
+
+	store, err := NewSQLiteCorrelationStore(filepath.Join(t.TempDir(), "correlations.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Correlations = store
+
+	b.handleUpdate(replyUpdate("hello"))
+
+	calls := api.waitCalled(t, "sendMessage", 1)
+	if !strings.Contains(calls[0]["text"], errNoConversation.Error()) ||
+		calls[0]["chat_id"] != fmt.Sprint(testGroupChatId) {
+		t.Fatalf("expected the agent to be told, got %+v", calls[0])
+	}
+	if sent := b.Messenger.(*m.RecordingSender).Sent(); len(sent) != 0 {
+		t.Fatalf("the phone must not be parsed from the message text, got %+v", sent)
+	}
+}
+
+func TestReplyFromUnroutedChatIsIgnored(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	b := newTestBot(t, api)
+	b.Routing = &Routing{Routes: []Route{{Name: "sales", ChatId: -100456, Keywords: []string{"buy"}}}}
+
+	// any basic group the bot is added to must not reply to the whatsapp contacts
+	update := replyUpdate("hello")
+	other := &tgBotApi.Chat{ID: -789, Type: "group", Title: "others"}
+	update.Message.Chat, update.Message.ReplyToMessage.Chat = other, other
+	b.handleUpdate(update)
+
+	time.Sleep(50 * time.Millisecond)
+	if calls := api.called("sendMessage"); len(calls) != 0 {
+		t.Fatalf("the reply from an unrouted chat must be ignored, got %+v", calls)
+	}
+	if sent := b.Messenger.(*m.RecordingSender).Sent(); len(sent) != 0 {
+		t.Fatalf("the reply from an unrouted chat must not be sent, got %+v", sent)
+	}
+
+	if !b.isRoutedChat(&tgBotApi.Chat{ID: -100456, Type: "group"}) {
+		t.Fatal("expected the target chat of the route to be allowed")
+	}
+}
+
+func TestReplyWithoutMessenger(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	b := newTestBot(t, api)
+	b.Messenger = nil
+
+	update := replyUpdate("hello")
+	update.Message.ReplyToMessage.Text = buildTelegramMessage(time.Now(), "1", "6281234567890", "John", "hi")
+	b.handleUpdate(update)
+
+	calls := api.waitCalled(t, "sendMessage", 1)
+	if !strings.Contains(calls[0]["text"], noMessengerReply) {
+		t.Fatalf("expected the agent to be told, got %+v", calls[0])
+	}
+}
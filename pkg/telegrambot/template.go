@@ -0,0 +1,232 @@
+package telegrambot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tgBotApi "github.com/matterbridge/telegram-bot-api/v6"
+
+	"github.com/ardihikaru/go-modules/pkg/enums/emoji"
+	"github.com/ardihikaru/go-modules/pkg/utils/common"
+)
+
+// header fields of the message template
+const (
+	FieldTime  = "time"
+	FieldMsgId = "message_id"
+	FieldPhone = "phone"
+	FieldName  = "name"
+)
+
+// fieldLabels defines the label of each header field
+var fieldLabels = map[string]string{
+	FieldTime:  "TIME",
+	FieldMsgId: "MESSAGE_ID",
+	FieldPhone: "PHONE",
+	FieldName:  "FROM",
+}
+
+// MessageTemplate defines the layout of the whatsapp messages posted to telegram
+//
+// The rendered message is formatted as below, the customer text is escaped based on the parse mode:
+//
+//	SasaBot
+//	TIME: 2023-05-01 09:30 WIB
+//	MESSAGE_ID: 3EB0C431C26A1916E05B
+//	PHONE: 6281234567890
+//	FROM: John Doe ✅
+//
+//	<the message>
+type MessageTemplate struct {
+	// BotName is the title of the message header
+	BotName string
+
+	// Fields lists the header fields in order, e.g. FieldTime, FieldPhone
+	// without FieldPhone, replies can only be resolved by the correlation store
+	Fields []string
+
+	// TimeFormat is the layout of the time field (see time.Layout),
+	// empty uses common.ToDateString and common.ToTimeStringWithTz
+	TimeFormat string
+
+	// Location is the timezone of the time field, nil keeps the timezone of the whatsapp message
+	Location *time.Location
+
+	// TimezoneLabel is appended to the time field if TimeFormat is empty, e.g. "WIB"
+	TimezoneLabel string
+
+	// ParseMode is either empty (plain text), tgBotApi.ModeMarkdownV2, or tgBotApi.ModeHTML
+	ParseMode string
+}
+
+// DefaultMessageTemplate builds the message template with every header field, as plain text
+func DefaultMessageTemplate(botName string) *MessageTemplate {
+	return &MessageTemplate{
+		BotName: botName,
+		Fields:  []string{FieldTime, FieldMsgId, FieldPhone, FieldName},
+	}
+}
+
+// render renders the whatsapp message, the message is trimmed if the rendered text exceeds the max length
+// the length is counted after the entities parsing, as telegram does; zero means unlimited
+func (t *MessageTemplate) render(waMsg *WhatsappMessage, maxLength int) string {
+	msg := waMsg.Message
+	if maxLength > 0 {
+		// the plain header is as long as the parsed header
+		headerLength := textLength(t.renderWith("", waMsg, ""))
+		msg = truncateText(msg, maxLength-headerLength)
+	}
+
+	return t.renderWith(t.ParseMode, waMsg, msg)
+}
+
+// renderWith renders the whatsapp message with the designated parse mode
+func (t *MessageTemplate) renderWith(parseMode string, waMsg *WhatsappMessage, msg string) string {
+	var sb strings.Builder
+
+	if t.BotName != "" {
+		sb.WriteString(bold(parseMode, escapeText(parseMode, t.BotName)))
+		sb.WriteString("\n")
+	}
+
+	for _, field := range t.Fields {
+		label, ok := fieldLabels[field]
+		if !ok {
+			continue
+		}
+
+		var value string
+		switch field {
+		case FieldTime:
+			value = escapeText(parseMode, t.formatTime(waMsg.Timestamp))
+		case FieldMsgId:
+			value = escapeText(parseMode, waMsg.MsgId)
+		case FieldPhone:
+			value = escapeText(parseMode, waMsg.Phone)
+		case FieldName:
+			value = fmt.Sprintf("%s %s", escapeText(parseMode, waMsg.Name), emoji.CheckMark)
+		}
+		sb.WriteString(fmt.Sprintf("%s %s\n", bold(parseMode, escapeText(parseMode, label+":")), value))
+	}
+
+	sb.WriteString("\n")
+	sb.WriteString(escapeText(parseMode, msg))
+
+	return sb.String()
+}
+
+// formatTime formats the time field
+func (t *MessageTemplate) formatTime(ts time.Time) string {
+	if t.Location != nil {
+		ts = ts.In(t.Location)
+	}
+
+	if t.TimeFormat != "" {
+		return ts.Format(t.TimeFormat)
+	}
+
+	tz := t.TimezoneLabel
+	if tz == "" {
+		tz, _ = ts.Zone()
+	}
+
+	return fmt.Sprintf("%s %s", common.ToDateString(ts), common.ToTimeStringWithTz(ts, tz))
+}
+
+// parsePhone extracts the whatsapp phone from the text of a message rendered by this template
+// (or by buildTelegramMessage, whose header lines end with "|")
+// telegram returns the text without the formatting, hence only the plain label is matched
+// only the header is scanned, so that a customer message cannot spoof the phone
+func (t *MessageTemplate) parsePhone(text string) (string, bool) {
+	if !t.hasField(FieldPhone) {
+		return "", false
+	}
+
+	prefix := fieldLabels[FieldPhone] + ":"
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			// the header ends with an empty line
+			break
+		}
+		if strings.HasPrefix(line, prefix) {
+			phone := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, prefix), "|"))
+			return phone, phone != ""
+		}
+	}
+
+	return "", false
+}
+
+// hasField checks whether the header contains the field
+func (t *MessageTemplate) hasField(field string) bool {
+	for _, f := range t.Fields {
+		if f == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+// escapeText escapes the text, so that formatting characters in customer messages do not break sending
+func escapeText(parseMode, text string) string {
+	switch parseMode {
+	case tgBotApi.ModeMarkdownV2:
+		// unlike tgBotApi.EscapeText, the backslash itself is escaped as well
+		return strings.NewReplacer(
+			"\\", "\\\\", "_", "\\_", "*", "\\*", "[", "\\[", "]", "\\]", "(", "\\(", ")", "\\)",
+			"~", "\\~", "`", "\\`", ">", "\\>", "#", "\\#", "+", "\\+", "-", "\\-", "=", "\\=",
+			"|", "\\|", "{", "\\{", "}", "\\}", ".", "\\.", "!", "\\!",
+		).Replace(text)
+	case tgBotApi.ModeHTML:
+		return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+	default:
+		return text
+	}
+}
+
+// bold formats the escaped text as bold, based on the parse mode
+func bold(parseMode, text string) string {
+	switch parseMode {
+	case tgBotApi.ModeMarkdownV2:
+		return fmt.Sprintf("*%s*", text)
+	case tgBotApi.ModeHTML:
+		return fmt.Sprintf("<b>%s</b>", text)
+	default:
+		return text
+	}
+}
+
+// truncateText trims the text to the designated length, see textLength
+func truncateText(text string, maxLength int) string {
+	length := 0
+	for i, r := range text {
+		length += utf16Len(r)
+		if length > maxLength {
+			return text[:i]
+		}
+	}
+
+	return text
+}
+
+// textLength returns the length of the text as telegram counts it, i.e. in UTF-16 code units
+func textLength(text string) int {
+	length := 0
+	for _, r := range text {
+		length += utf16Len(r)
+	}
+
+	return length
+}
+
+// utf16Len returns the number of UTF-16 code units of the rune, as utf16.RuneLen (go 1.23) does
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+
+	return 1
+}
@@ -0,0 +1,53 @@
+package telegrambot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePhone(t *testing.T) {
+	tpl := DefaultMessageTemplate("SasaBot")
+	waMsg := &WhatsappMessage{
+		MsgId:     "3EB0C431C26A1916E05B",
+		Phone:     "6281234567890",
+		Name:      "John Doe",
+		Message:   "hello",
+		Timestamp: time.Now(),
+	}
+
+	phone, ok := tpl.parsePhone(tpl.render(waMsg, maxMessageLength))
+	if !ok || phone != "6281234567890" {
+		t.Fatalf("expected the phone of the header, got %q, %v", phone, ok)
+	}
+
+	// the customer message cannot spoof the phone
+	tpl.Fields = []string{FieldTime, FieldName}
+	waMsg.Message = "PHONE: 6289999999999"
+	phone, ok = tpl.parsePhone(tpl.render(waMsg, maxMessageLength))
+	if ok {
+		t.Fatalf("expected no phone without the phone field, got %q", phone)
+	}
+
+	// a header without the phone line, e.g. posted before the field was added
+	tpl.Fields = []string{FieldTime, FieldPhone, FieldName}
+	phone, ok = tpl.parsePhone("SasaBot\nFROM: John Doe\n\nPHONE: 6289999999999")
+	if ok {
+		t.Fatalf("expected the message body not to be scanned, got %q", phone)
+	}
+}
+
+func TestParseLegacyPhone(t *testing.T) {
+	text := buildTelegramMessage(time.Now(), "3EB0C431C26A1916E05B", "6281234567890", "John Doe",
+		"hi\n\nPHONE:6289999999999|")
+
+	phone, ok := legacyTemplate.parsePhone(text)
+	if !ok || phone != "6281234567890" {
+		t.Fatalf("expected the phone of the header, got %q, %v", phone, ok)
+	}
+
+	// a forwarded customer text which only looks like the message
+	phone, ok = legacyTemplate.parsePhone("hi|there|6289999999999|\n\nPHONE:6289999999999|")
+	if ok {
+		t.Fatalf("expected no phone outside the header, got %q", phone)
+	}
+}
@@ -0,0 +1,331 @@
+package telegrambot
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	tgBotApi "github.com/matterbridge/telegram-bot-api/v6"
+
+	"github.com/ardihikaru/go-modules/pkg/enums/emoji"
+	"github.com/ardihikaru/go-modules/pkg/utils/httputils"
+	ph "github.com/ardihikaru/go-modules/pkg/utils/phone"
+)
+
+// ticket statuses
+const (
+	TicketOpen    = "open"
+	TicketClaimed = "claimed"
+	TicketClosed  = "closed"
+)
+
+// defaultTicketLimit defines the default number of tickets returned by a query
+const defaultTicketLimit = 20
+
+var (
+	ErrTicketNotFound = errors.New("ticket not found")
+	errTicketRejected = errors.New("ticket change is rejected")
+)
+
+// Ticket tracks the assignment of a whatsapp conversation to a telegram agent
+// a conversation has at most one active (open or claimed) ticket, a new message after it is closed opens a new one
+type Ticket struct {
+	Id           int64     `json:"id"`
+	Phone        string    `json:"phone"`
+	Name         string    `json:"name"`
+	TgChatId     int64     `json:"tg_chat_id"`
+	Status       string    `json:"status"`
+	AssigneeId   int64     `json:"assignee_id,omitempty"`
+	AssigneeName string    `json:"assignee_name,omitempty"`
+	OpenedAt     time.Time `json:"opened_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	ClosedAt     time.Time `json:"closed_at"` // zero if it is still active
+}
+
+// TicketFilter defines the criteria of the ticket query, the zero values are ignored
+type TicketFilter struct {
+	Status string
+	Limit  int // zero uses the default limit
+	Offset int
+}
+
+// limit returns the number of tickets to return
+func (f *TicketFilter) limit() int {
+	if f.Limit <= 0 {
+		return defaultTicketLimit
+	}
+
+	return f.Limit
+}
+
+// TicketStore defines the storage of the tickets
+type TicketStore interface {
+	// OpenTicket returns the active ticket of the whatsapp phone, or opens the designated ticket if there is none
+	OpenTicket(t *Ticket) (*Ticket, error)
+
+	// ActiveTicket gets the active ticket of the whatsapp phone, returns ErrTicketNotFound if not exists
+	ActiveTicket(phone string) (*Ticket, error)
+
+	// UpdateTicket applies the change to the active ticket of the whatsapp phone atomically,
+	// the change is discarded if it returns an error
+	UpdateTicket(phone string, change func(t *Ticket) error) (*Ticket, error)
+
+	// ListTickets gets the tickets matching the filter, the latest opened first,
+	// and returns the total number of the matching tickets
+	ListTickets(f TicketFilter) ([]*Ticket, int64, error)
+}
+
+// openTicket opens a ticket for the forwarded whatsapp message, unless the conversation already has one
+func (b *TelegramBot) openTicket(chatId int64, waMsg *WhatsappMessage) {
+	if b.Tickets == nil || waMsg.Outgoing {
+		return
+	}
+
+	now := time.Now().UTC()
+	_, err := b.Tickets.OpenTicket(&Ticket{
+		Phone:     waMsg.Phone,
+		Name:      waMsg.Name,
+		TgChatId:  chatId,
+		Status:    TicketOpen,
+		OpenedAt:  now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		b.log.Warn(fmt.Sprintf("failed to open the ticket of [%s] -> %s", waMsg.Phone, err.Error()))
+	}
+}
+
+// checkAssignee checks whether the agent may reply to the whatsapp phone, based on its ticket assignee
+// the reply of another agent is either blocked or sent with a warning, see BlockUnassignedReplies
+func (b *TelegramBot) checkAssignee(message *tgBotApi.Message, phone string) bool {
+	if b.Tickets == nil || message.From == nil {
+		return true
+	}
+
+	t, err := b.Tickets.ActiveTicket(phone)
+	if errors.Is(err, ErrTicketNotFound) {
+		return true
+	}
+	if err != nil {
+		// ticketing must not stop the agents from replying
+		b.log.Warn(fmt.Sprintf("failed to get the ticket of [%s] -> %s", phone, err.Error()))
+		return true
+	}
+
+	if t.Status != TicketClaimed || t.AssigneeId == message.From.ID {
+		return true
+	}
+
+	notice := fmt.Sprintf("ticket #%d of [+%s] is claimed by %s", t.Id, phone, t.AssigneeName)
+	if b.BlockUnassignedReplies {
+		b.notifyFailure(message, notice)
+		return false
+	}
+
+	_, err = b.replyTo(message, fmt.Sprintf("%s %s, your reply is sent anyway", emoji.Warning, notice))
+	if err != nil {
+		b.log.Warn(fmt.Sprintf("failed to send the ticket warning -> %s", err.Error()))
+	}
+
+	return true
+}
+
+// cmdClaim assigns the ticket to the agent: /claim [phone]
+func (b *TelegramBot) cmdClaim(message *tgBotApi.Message) string {
+	phone, reply := b.ticketCommandPhone(message, cmdClaim)
+	if reply != "" {
+		return reply
+	}
+
+	// a conversation started by /send has no ticket yet
+	now := time.Now().UTC()
+	_, err := b.Tickets.OpenTicket(&Ticket{
+		Phone:     phone,
+		TgChatId:  message.Chat.ID,
+		Status:    TicketOpen,
+		OpenedAt:  now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		b.log.Warn(fmt.Sprintf("failed to open the ticket of [%s] -> %s", phone, err.Error()))
+		return fmt.Sprintf("failed to claim the ticket of [+%s]", phone)
+	}
+
+	var assignee string
+	t, err := b.Tickets.UpdateTicket(phone, func(t *Ticket) error {
+		if t.Status == TicketClaimed && t.AssigneeId != message.From.ID {
+			assignee = t.AssigneeName
+			return errTicketRejected
+		}
+
+		t.Status = TicketClaimed
+		t.AssigneeId = message.From.ID
+		t.AssigneeName = agentName(message.From)
+		t.UpdatedAt = time.Now().UTC()
+
+		return nil
+	})
+
+	return b.ticketReply(t, err, phone, "claimed", assignee)
+}
+
+// cmdRelease unassigns the ticket, only by its assignee: /release [phone]
+func (b *TelegramBot) cmdRelease(message *tgBotApi.Message) string {
+	phone, reply := b.ticketCommandPhone(message, cmdRelease)
+	if reply != "" {
+		return reply
+	}
+
+	var assignee string
+	t, err := b.Tickets.UpdateTicket(phone, func(t *Ticket) error {
+		if t.Status != TicketClaimed || t.AssigneeId != message.From.ID {
+			assignee = t.AssigneeName
+			return errTicketRejected
+		}
+
+		t.Status = TicketOpen
+		t.AssigneeId = 0
+		t.AssigneeName = ""
+		t.UpdatedAt = time.Now().UTC()
+
+		return nil
+	})
+
+	return b.ticketReply(t, err, phone, "released", assignee)
+}
+
+// cmdClose resolves the ticket, unless it is claimed by another agent: /close [phone]
+func (b *TelegramBot) cmdClose(message *tgBotApi.Message) string {
+	phone, reply := b.ticketCommandPhone(message, cmdClose)
+	if reply != "" {
+		return reply
+	}
+
+	var assignee string
+	t, err := b.Tickets.UpdateTicket(phone, func(t *Ticket) error {
+		if t.Status == TicketClaimed && t.AssigneeId != message.From.ID {
+			assignee = t.AssigneeName
+			return errTicketRejected
+		}
+
+		t.Status = TicketClosed
+		t.UpdatedAt = time.Now().UTC()
+		t.ClosedAt = t.UpdatedAt
+
+		return nil
+	})
+
+	return b.ticketReply(t, err, phone, "closed", assignee)
+}
+
+// ticketCommandPhone resolves the whatsapp phone of the ticket command, either from its argument,
+// from the forum topic, or from the replied bot message; a non-empty reply is returned on failure
+func (b *TelegramBot) ticketCommandPhone(message *tgBotApi.Message, command string) (string, string) {
+	if b.Tickets == nil {
+		return "", "ticketing is not available, since the ticket store is not configured"
+	}
+	if message.From == nil || !b.authorizeAgent(message) {
+		return "", "you are not allowed to handle the WhatsApp conversations"
+	}
+
+	if args := strings.Fields(message.CommandArguments()); len(args) > 0 {
+		phone, err := ph.ToJIDUser(args[0], ph.DefaultRegion)
+		if err != nil {
+			return "", fmt.Sprintf("invalid phone [%s]: %s", args[0], err.Error())
+		}
+		return phone, ""
+	}
+
+	phone, ok, err := b.resolveRecipient(message)
+	if err != nil {
+		return "", err.Error()
+	}
+	if !ok {
+		return "", fmt.Sprintf("usage: /%s <phone>, or reply /%s to a WhatsApp message", command, command)
+	}
+
+	return phone, ""
+}
+
+// ticketReply builds the reply of the ticket command
+func (b *TelegramBot) ticketReply(t *Ticket, err error, phone, action, assignee string) string {
+	switch {
+	case err == nil:
+		return fmt.Sprintf("ticket #%d of [+%s] has been %s", t.Id, phone, action)
+	case errors.Is(err, ErrTicketNotFound):
+		return fmt.Sprintf("there is no active ticket of [+%s]", phone)
+	case errors.Is(err, errTicketRejected) && assignee != "":
+		return fmt.Sprintf("the ticket of [+%s] is claimed by %s", phone, assignee)
+	case errors.Is(err, errTicketRejected):
+		return fmt.Sprintf("the ticket of [+%s] is not claimed", phone)
+	default:
+		b.log.Warn(fmt.Sprintf("failed to update the ticket of [%s] -> %s", phone, err.Error()))
+		return fmt.Sprintf("failed to update the ticket of [+%s]", phone)
+	}
+}
+
+// agentName returns the display name of the telegram user
+func agentName(user *tgBotApi.User) string {
+	if user.UserName != "" {
+		return "@" + user.UserName
+	}
+
+	return strings.TrimSpace(fmt.Sprintf("%s %s", user.FirstName, user.LastName))
+}
+
+// TicketHandler builds the HTTP handler to list the tickets, e.g. mounted on "/tickets"
+//
+// Query parameters: status, limit, and offset; the response total is the number of the matching tickets
+func TicketHandler(store TicketStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, appErrCode, err := ticketFilterFromQuery(r.URL.Query())
+		if err != nil {
+			httputils.RenderErrResponse(w, r, httputils.ResponseText("", appErrCode), int64(appErrCode),
+				http.StatusBadRequest, err)
+			return
+		}
+
+		tickets, total, err := store.ListTickets(f)
+		if err != nil {
+			httputils.RenderErrResponse(w, r, httputils.ResponseText("", httputils.FailedToFetchData),
+				httputils.FailedToFetchData, http.StatusInternalServerError, err)
+			return
+		}
+
+		_ = httputils.RenderOKResponse(w, r, httputils.Response{
+			Data:  tickets,
+			Total: total,
+		})
+	})
+}
+
+// ticketFilterFromQuery extracts the ticket filter from the URL query
+func ticketFilterFromQuery(q url.Values) (TicketFilter, int, error) {
+	var err error
+	f := TicketFilter{Status: q.Get("status")}
+
+	switch f.Status {
+	case "", TicketOpen, TicketClaimed, TicketClosed:
+	default:
+		return f, httputils.InvalidURLParameters, fmt.Errorf("invalid status [%s]", f.Status)
+	}
+
+	if v := q.Get("limit"); v != "" {
+		f.Limit, err = strconv.Atoi(v)
+		if err != nil || f.Limit < 0 {
+			return f, httputils.InvalidLimitValue, fmt.Errorf("invalid limit [%s]", v)
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		f.Offset, err = strconv.Atoi(v)
+		if err != nil || f.Offset < 0 {
+			return f, httputils.InvalidOffsetValue, fmt.Errorf("invalid offset [%s]", v)
+		}
+	}
+
+	return f, 0, nil
+}
@@ -0,0 +1,269 @@
+package telegrambot
+
+import (
+	"encoding/json"
+	"fmt"
+
+	goRedis "github.com/go-redis/redis"
+
+	"github.com/ardihikaru/go-modules/pkg/redis"
+)
+
+// prefixes of the redis keys of the tickets
+const (
+	ticketKeyPrefix       = "tgbot:ticket"
+	ticketSeqKey          = "tgbot:ticket:seq"
+	ticketActiveKeyPrefix = "tgbot:ticket:active"
+	ticketListKey         = "tgbot:tickets"
+)
+
+// maxTicketTxRetries defines the number of retries of a ticket transaction conflicting with another agent
+const maxTicketTxRetries = 10
+
+// RedisTicketStore stores the tickets in redis
+//
+// Each ticket is stored as JSON, indexed by the sorted sets of every ticket and of each status
+// (scored by the opening time), and the active ticket ID of each whatsapp phone is kept as well.
+// The changes are applied in optimistic transactions, so that two agents cannot claim the same ticket.
+type RedisTicketStore struct {
+	rdb *redis.Redis
+}
+
+// NewRedisTicketStore builds the redis ticket store
+func NewRedisTicketStore(rdb *redis.Redis) *RedisTicketStore {
+	return &RedisTicketStore{rdb: rdb}
+}
+
+// OpenTicket returns the active ticket of the whatsapp phone, or opens the designated ticket if there is none
+func (s *RedisTicketStore) OpenTicket(t *Ticket) (*Ticket, error) {
+	activeKey := ticketActiveKey(t.Phone)
+
+	var opened *Ticket
+	err := s.watch(func(tx *goRedis.Tx) error {
+		active, err := s.activeTicket(tx, t.Phone)
+		if err == nil {
+			opened = active
+			return nil
+		}
+		if err != ErrTicketNotFound {
+			return err
+		}
+
+		// an ID wasted by a conflicting transaction is never reused
+		t.Id, err = s.rdb.Client.Incr(ticketSeqKey).Result()
+		if err != nil {
+			return err
+		}
+
+		p, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Pipelined(func(pipe goRedis.Pipeliner) error {
+			pipe.Set(ticketKey(t.Id), p, 0)
+			pipe.Set(activeKey, t.Id, 0)
+			pipe.ZAdd(ticketListKey, goRedis.Z{Score: ticketScore(t), Member: t.Id})
+			pipe.ZAdd(ticketStatusKey(t.Status), goRedis.Z{Score: ticketScore(t), Member: t.Id})
+			return nil
+		})
+		opened = t
+
+		return err
+	}, activeKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return opened, nil
+}
+
+// ActiveTicket gets the active ticket of the whatsapp phone
+func (s *RedisTicketStore) ActiveTicket(phone string) (*Ticket, error) {
+	id, err := s.rdb.Client.Get(ticketActiveKey(phone)).Int64()
+	if err == goRedis.Nil {
+		return nil, ErrTicketNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getTicket(id)
+}
+
+// UpdateTicket applies the change to the active ticket of the whatsapp phone atomically
+// a closed ticket is no longer the active ticket of the phone
+func (s *RedisTicketStore) UpdateTicket(phone string, change func(t *Ticket) error) (*Ticket, error) {
+	activeKey := ticketActiveKey(phone)
+
+	var updated *Ticket
+	err := s.watch(func(tx *goRedis.Tx) error {
+		t, err := s.activeTicket(tx, phone)
+		if err != nil {
+			return err
+		}
+
+		prevStatus := t.Status
+		err = change(t)
+		if err != nil {
+			return err
+		}
+
+		p, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Pipelined(func(pipe goRedis.Pipeliner) error {
+			pipe.Set(ticketKey(t.Id), p, 0)
+			if t.Status != prevStatus {
+				pipe.ZRem(ticketStatusKey(prevStatus), t.Id)
+				pipe.ZAdd(ticketStatusKey(t.Status), goRedis.Z{Score: ticketScore(t), Member: t.Id})
+			}
+			if t.Status == TicketClosed {
+				pipe.Del(activeKey)
+			}
+			return nil
+		})
+		updated = t
+
+		return err
+	}, activeKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// ListTickets gets the tickets matching the filter, the latest opened first
+func (s *RedisTicketStore) ListTickets(f TicketFilter) ([]*Ticket, int64, error) {
+	key := ticketListKey
+	if f.Status != "" {
+		key = ticketStatusKey(f.Status)
+	}
+
+	total, err := s.rdb.Client.ZCard(key).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	start := int64(f.Offset)
+	ids, err := s.rdb.Client.ZRevRange(key, start, start+int64(f.limit())-1).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	tickets := make([]*Ticket, 0, len(ids))
+	if len(ids) == 0 {
+		return tickets, total, nil
+	}
+
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, fmt.Sprintf("%s:%s", ticketKeyPrefix, id))
+	}
+
+	vals, err := s.rdb.Client.MGet(keys...).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, val := range vals {
+		// the ticket itself is never removed, but skips it anyway if it is missing
+		str, ok := val.(string)
+		if !ok {
+			continue
+		}
+
+		var t Ticket
+		err = json.Unmarshal([]byte(str), &t)
+		if err != nil {
+			return nil, 0, err
+		}
+		tickets = append(tickets, &t)
+	}
+
+	return tickets, total, nil
+}
+
+// watch runs the function in an optimistic transaction watching the keys,
+// and retries it if the keys are changed by another agent meanwhile
+func (s *RedisTicketStore) watch(fn func(tx *goRedis.Tx) error, keys ...string) error {
+	for i := 0; i < maxTicketTxRetries; i++ {
+		err := s.rdb.Client.Watch(fn, keys...)
+		if err != goRedis.TxFailedErr {
+			return err
+		}
+	}
+
+	return goRedis.TxFailedErr
+}
+
+// activeTicket gets the active ticket of the whatsapp phone within the transaction
+// the ticket itself is watched as well, since it may be changed without changing the active key
+func (s *RedisTicketStore) activeTicket(tx *goRedis.Tx, phone string) (*Ticket, error) {
+	id, err := tx.Get(ticketActiveKey(phone)).Int64()
+	if err == goRedis.Nil {
+		return nil, ErrTicketNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Watch(ticketKey(id)).Err()
+	if err != nil {
+		return nil, err
+	}
+
+	val, err := tx.Get(ticketKey(id)).Result()
+	if err == goRedis.Nil {
+		return nil, ErrTicketNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var t Ticket
+	err = json.Unmarshal([]byte(val), &t)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// getTicket gets the designated ticket
+func (s *RedisTicketStore) getTicket(id int64) (*Ticket, error) {
+	var t Ticket
+
+	err := s.rdb.Get(ticketKey(id), &t)
+	if err == goRedis.Nil {
+		return nil, ErrTicketNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// ticketKey builds the redis key of the designated ticket
+func ticketKey(id int64) string {
+	return fmt.Sprintf("%s:%d", ticketKeyPrefix, id)
+}
+
+// ticketActiveKey builds the redis key of the active ticket ID of the designated whatsapp phone
+func ticketActiveKey(phone string) string {
+	return fmt.Sprintf("%s:%s", ticketActiveKeyPrefix, phone)
+}
+
+// ticketStatusKey builds the redis key of the sorted set of the tickets with the designated status
+func ticketStatusKey(status string) string {
+	return fmt.Sprintf("%s:%s", ticketListKey, status)
+}
+
+// ticketScore returns the score of the ticket in the sorted sets
+func ticketScore(t *Ticket) float64 {
+	return float64(t.OpenedAt.UnixNano())
+}
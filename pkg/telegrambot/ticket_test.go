@@ -0,0 +1,50 @@
+package telegrambot
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	tgBotApi "github.com/matterbridge/telegram-bot-api/v6"
+
+	"github.com/ardihikaru/go-modules/pkg/logger"
+	"github.com/ardihikaru/go-modules/pkg/redis"
+)
+
+func TestTicketCommandsRespectAssignee(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb, err := redis.GetRedis(mr.Addr(), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = rdb.Client.Close() })
+
+	log, err := logger.New("error", "console")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &TelegramBot{log: log, Tickets: NewRedisTicketStore(rdb)}
+
+	owner := commandMessage("/claim 081234567890")
+	other := commandMessage("/claim 081234567890")
+	other.From = &tgBotApi.User{ID: 8, UserName: "other"}
+
+	if reply := b.cmdClaim(owner); reply != "ticket #1 of [+6281234567890] has been claimed" {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+	if reply := b.cmdClaim(other); reply != "the ticket of [+6281234567890] is claimed by agent" {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+	if reply := b.cmdClose(other); reply != "the ticket of [+6281234567890] is claimed by agent" {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+
+	if reply := b.cmdRelease(owner); reply != "ticket #1 of [+6281234567890] has been released" {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+	if reply := b.cmdClose(other); reply != "ticket #1 of [+6281234567890] has been closed" {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+	if reply := b.cmdRelease(owner); reply != "there is no active ticket of [+6281234567890]" {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+}
@@ -0,0 +1,116 @@
+package telegrambot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	tgBotApi "github.com/matterbridge/telegram-bot-api/v6"
+)
+
+// maxTopicNameLength defines the maximum length of a forum topic name allowed by telegram
+const maxTopicNameLength = 128
+
+// ErrTopicNotFound is returned when the whatsapp conversation has no forum topic yet
+var ErrTopicNotFound = errors.New("forum topic not found")
+
+// Topic maps a telegram forum topic with its whatsapp conversation
+type Topic struct {
+	TgChatId  int64     `json:"tg_chat_id"`
+	ThreadId  int       `json:"thread_id"`
+	WaSession string    `json:"wa_session"`
+	WaChatJID string    `json:"wa_chat_jid"`
+	Phone     string    `json:"phone"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TopicStore defines the storage of the forum topics
+type TopicStore interface {
+	// SaveTopic stores the forum topic of the whatsapp conversation
+	SaveTopic(t *Topic) error
+
+	// GetTopicByPhone gets the forum topic of the designated whatsapp phone, returns ErrTopicNotFound if not exists
+	GetTopicByPhone(tgChatId int64, phone string) (*Topic, error)
+
+	// GetTopicByThread gets the forum topic of the designated thread, returns ErrTopicNotFound if not exists
+	GetTopicByThread(tgChatId int64, threadId int) (*Topic, error)
+}
+
+// topicFor gets the forum topic of the whatsapp conversation, a new topic is created if not exists yet
+func (b *TelegramBot) topicFor(chatId int64, waMsg *WhatsappMessage) (*Topic, error) {
+	topic, err := b.Topics.GetTopicByPhone(chatId, waMsg.Phone)
+	if err == nil {
+		return topic, nil
+	}
+	if !errors.Is(err, ErrTopicNotFound) {
+		return nil, err
+	}
+
+	// creates a new topic in the forum supergroup
+	resp, err := b.Bot.Request(tgBotApi.CreateForumTopicConfig{
+		BaseForum: tgBotApi.BaseForum{ChatID: chatId},
+		Name:      topicName(waMsg.Name, waMsg.Phone),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var forumTopic tgBotApi.ForumTopic
+	err = json.Unmarshal(resp.Result, &forumTopic)
+	if err != nil {
+		return nil, err
+	}
+
+	topic = &Topic{
+		TgChatId:  chatId,
+		ThreadId:  forumTopic.MessageThreadID,
+		WaSession: waMsg.Session,
+		WaChatJID: waMsg.ChatJID,
+		Phone:     waMsg.Phone,
+		Name:      waMsg.Name,
+		CreatedAt: time.Now().UTC(),
+	}
+	err = b.Topics.SaveTopic(topic)
+	if err != nil {
+		return nil, err
+	}
+	b.log.Info(fmt.Sprintf("a new forum topic [%d] has been created for [%s]", topic.ThreadId, waMsg.Phone))
+
+	return topic, nil
+}
+
+// resolveTopicPhone resolves the whatsapp phone of a message typed in a forum topic
+func (b *TelegramBot) resolveTopicPhone(msg *tgBotApi.Message) (string, bool) {
+	if b.Topics == nil || !msg.IsTopicMessage || msg.MessageThreadID == 0 {
+		return "", false
+	}
+
+	topic, err := b.Topics.GetTopicByThread(msg.Chat.ID, msg.MessageThreadID)
+	if err != nil {
+		if !errors.Is(err, ErrTopicNotFound) {
+			b.log.Warn(fmt.Sprintf("failed to get the forum topic -> %s", err.Error()))
+		}
+		return "", false
+	}
+
+	return topic.Phone, true
+}
+
+// topicName builds the forum topic name, e.g. "John Doe (+6281234567890)"
+func topicName(name, phone string) string {
+	topic := fmt.Sprintf("%s (+%s)", name, phone)
+	if name == "" {
+		topic = fmt.Sprintf("+%s", phone)
+	}
+
+	// trims the name, since telegram rejects long topic names
+	for utf8.RuneCountInString(topic) > maxTopicNameLength {
+		_, size := utf8.DecodeLastRuneInString(topic)
+		topic = topic[:len(topic)-size]
+	}
+
+	return topic
+}
@@ -0,0 +1,267 @@
+package telegrambot
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+
+	tgBotApi "github.com/matterbridge/telegram-bot-api/v6"
+)
+
+// secretTokenHeader defines the header which carries the secret token of the webhook, sent by telegram
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+const (
+	// webhookQueueSize bounds the updates acknowledged but not processed yet, the excess is refused to be resent later
+	webhookQueueSize = 100
+
+	// webhookDedupSize defines the number of the recent update IDs kept to drop the updates resent by telegram
+	webhookDedupSize = 1000
+)
+
+// WebhookConfig defines the configuration of the webhook (push) mode
+type WebhookConfig struct {
+	// URL is the public HTTPS URL of the mounted WebhookHandler
+	URL string
+
+	// SecretToken is sent by telegram on every update, and verified by WebhookHandler
+	// empty generates a random one, since the webhook URL is publicly accessible
+	SecretToken string
+
+	// MaxConnections is the maximum number of simultaneous connections, zero uses the telegram default (40)
+	MaxConnections int
+
+	// DropPendingUpdates drops the updates captured while the webhook was not set
+	DropPendingUpdates bool
+}
+
+// InitWebhook initializes the webhook (push) mode, an alternative of Init (long polling)
+// the WebhookHandler must be mounted on the designated URL, e.g. r.Mount("/telegram", b.WebhookHandler(ctx))
+func (b *TelegramBot) InitWebhook(cfg WebhookConfig) error {
+	b.log.Info(fmt.Sprintf("Authorized on account [%s]", b.Bot.Self.UserName))
+
+	err := b.SetWebhook(cfg)
+	if err != nil {
+		return err
+	}
+
+	b.initCommands()
+
+	return nil
+}
+
+// SetWebhook registers the webhook URL on telegram, the long polling will not receive any update afterwards
+// a random secret token is generated if it is not configured, see SetWebhookSecret
+func (b *TelegramBot) SetWebhook(cfg WebhookConfig) error {
+	wh, err := tgBotApi.NewWebhook(cfg.URL)
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL [%s]: %w", cfg.URL, err)
+	}
+
+	if cfg.SecretToken == "" {
+		cfg.SecretToken, err = generateSecretToken()
+		if err != nil {
+			return fmt.Errorf("failed to generate the webhook secret token: %w", err)
+		}
+	}
+	wh.SecretToken = cfg.SecretToken
+	wh.MaxConnections = cfg.MaxConnections
+	wh.DropPendingUpdates = cfg.DropPendingUpdates
+
+	_, err = b.Bot.Request(wh)
+	if err != nil {
+		return fmt.Errorf("failed to set the webhook: %w", err)
+	}
+	b.SetWebhookSecret(cfg.SecretToken)
+
+	return nil
+}
+
+// DeleteWebhook removes the webhook, e.g. to switch back to the long polling
+func (b *TelegramBot) DeleteWebhook(dropPendingUpdates bool) error {
+	_, err := b.Bot.Request(tgBotApi.DeleteWebhookConfig{DropPendingUpdates: dropPendingUpdates})
+	if err != nil {
+		return fmt.Errorf("failed to delete the webhook: %w", err)
+	}
+	b.SetWebhookSecret("")
+
+	return nil
+}
+
+// SetWebhookSecret sets the secret token verified by WebhookHandler, SetWebhook sets (or generates) it as well
+// set it explicitly on the replicas which only serve the webhook without registering it,
+// the webhook requests are refused while it is empty
+func (b *TelegramBot) SetWebhookSecret(secret string) {
+	b.webhookMu.Lock()
+	defer b.webhookMu.Unlock()
+
+	b.webhookSecret = secret
+}
+
+// WebhookSecret returns the secret token verified by WebhookHandler
+func (b *TelegramBot) WebhookSecret() string {
+	b.webhookMu.RLock()
+	defer b.webhookMu.RUnlock()
+
+	return b.webhookSecret
+}
+
+// WebhookHandler builds the HTTP handler which receives the updates pushed by telegram
+// the updates are acknowledged first and processed in the background with the same logic as Run,
+// so that a slow reply does not outlast the telegram timeout, and the resent updates are dropped
+// every request is refused until the secret token is set, either by SetWebhook or explicitly
+// the updates are processed until the context (of the first call) is cancelled, the requests are refused afterwards
+func (b *TelegramBot) WebhookHandler(ctx context.Context) http.Handler {
+	b.webhookOnce.Do(func() {
+		b.webhookUpdates = make(chan tgBotApi.Update, webhookQueueSize)
+		go b.processWebhookUpdates(ctx)
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !b.validSecretToken(r.Header.Get(secretTokenHeader)) {
+			b.log.Warn("captured a webhook request with an invalid secret token")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		update, err := b.Bot.HandleUpdate(r)
+		if err != nil {
+			b.log.Warn(fmt.Sprintf("failed to extract the webhook update -> %s", err.Error()))
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+
+		if !b.webhookDedup.add(update.UpdateID) {
+			b.log.Debug(fmt.Sprintf("drops the resent webhook update [%d]", update.UpdateID))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		if !b.queueWebhookUpdate(*update) {
+			// any non-2xx response makes telegram resend the same update
+			b.webhookDedup.remove(update.UpdateID)
+			b.log.Warn(fmt.Sprintf("webhook queue is full or closed, refuses update [%d]", update.UpdateID))
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// queueWebhookUpdate queues the update to be processed, it returns false if the queue is full or closed
+func (b *TelegramBot) queueWebhookUpdate(update tgBotApi.Update) bool {
+	b.webhookMu.RLock()
+	defer b.webhookMu.RUnlock()
+
+	if b.webhookClosed {
+		return false
+	}
+
+	select {
+	case b.webhookUpdates <- update:
+		return true
+	default:
+		return false
+	}
+}
+
+// processWebhookUpdates dispatches the acknowledged webhook updates in order until the context is cancelled
+// the queue is closed on shutdown, and the updates already acknowledged to telegram are dispatched before returning
+func (b *TelegramBot) processWebhookUpdates(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			b.closeWebhook()
+			b.log.Info("stops processing the webhook updates")
+			return
+		case update := <-b.webhookUpdates:
+			b.dispatchUpdate(update)
+		}
+	}
+}
+
+// closeWebhook refuses the next webhook updates, and dispatches the queued ones
+func (b *TelegramBot) closeWebhook() {
+	b.webhookMu.Lock()
+	b.webhookClosed = true
+	b.webhookMu.Unlock()
+
+	// nothing is queued once the queue is closed
+	for {
+		select {
+		case update := <-b.webhookUpdates:
+			b.dispatchUpdate(update)
+		default:
+			return
+		}
+	}
+}
+
+// validSecretToken verifies the secret token of the webhook request, it fails if no secret token is set
+func (b *TelegramBot) validSecretToken(token string) bool {
+	secret := b.WebhookSecret()
+	if secret == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+}
+
+// generateSecretToken generates a random secret token, telegram allows A-Z, a-z, 0-9, _ and -
+func generateSecretToken() (string, error) {
+	p := make([]byte, 32)
+	_, err := rand.Read(p)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(p), nil
+}
+
+// updateDedup keeps the recent update IDs, the oldest is removed once it is full; the zero value is ready to use
+type updateDedup struct {
+	mu    sync.Mutex
+	seen  map[int]struct{}
+	order []int
+}
+
+// add records the update ID, it returns false if it has been recorded
+func (d *updateDedup) add(id int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.seen == nil {
+		d.seen = make(map[int]struct{})
+	}
+	if _, ok := d.seen[id]; ok {
+		return false
+	}
+
+	if len(d.order) >= webhookDedupSize {
+		delete(d.seen, d.order[0])
+		d.order = d.order[1:]
+	}
+	d.seen[id] = struct{}{}
+	d.order = append(d.order, id)
+
+	return true
+}
+
+// remove forgets the update ID, e.g. if it is refused to be resent later
+func (d *updateDedup) remove(id int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.seen, id)
+	for i, seen := range d.order {
+		if seen == id {
+			d.order = append(d.order[:i], d.order[i+1:]...)
+			break
+		}
+	}
+}
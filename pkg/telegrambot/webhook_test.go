@@ -0,0 +1,284 @@
+package telegrambot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ardihikaru/go-modules/pkg/logger"
+	m "github.com/ardihikaru/go-modules/pkg/messenger"
+)
+
+const testGroupChatId int64 = -100123
+
+// fakeTelegramAPI stands in for the telegram bot API server, and records the called methods
+type fakeTelegramAPI struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	calls map[string][]map[string]string
+
+	// block delays the sendMessage responses until it is closed, if exists
+	block chan struct{}
+
+	// override responds instead of the fake API if exists and it returns true
+	override func(w http.ResponseWriter, method string) bool
+}
+
+func newFakeTelegramAPI(t *testing.T) *fakeTelegramAPI {
+	api := &fakeTelegramAPI{calls: make(map[string][]map[string]string)}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		_ = r.ParseForm()
+
+		params := make(map[string]string)
+		for key := range r.Form {
+			params[key] = r.Form.Get(key)
+		}
+		api.mu.Lock()
+		api.calls[method] = append(api.calls[method], params)
+		block, override := api.block, api.override
+		api.mu.Unlock()
+
+		if override != nil && override(w, method) {
+			return
+		}
+
+		switch method {
+		case "getMe":
+			_, _ = fmt.Fprint(w, `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"bot","username":"test_bot"}}`)
+		case "sendMessage":
+			if block != nil {
+				<-block
+			}
+			_, _ = fmt.Fprintf(w, `{"ok":true,"result":{"message_id":10,"chat":{"id":%s}}}`, params["chat_id"])
+		default:
+			_, _ = fmt.Fprint(w, `{"ok":true,"result":true}`)
+		}
+	}))
+	t.Cleanup(api.Close)
+
+	return api
+}
+
+// called returns the parameters of the calls of the method
+func (api *fakeTelegramAPI) called(method string) []map[string]string {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	return append([]map[string]string(nil), api.calls[method]...)
+}
+
+// waitCalled waits until the method has been called the designated number of times
+func (api *fakeTelegramAPI) waitCalled(t *testing.T, method string, n int) []map[string]string {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if calls := api.called(method); len(calls) >= n {
+			return calls
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected %d calls of [%s], got %d", n, method, len(api.called(method)))
+
+	return nil
+}
+
+// newTestBot connects the bot to the fake telegram API
+func newTestBot(t *testing.T, api *fakeTelegramAPI) *TelegramBot {
+	t.Helper()
+
+	log, err := logger.New("error", "console")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groupChatId := testGroupChatId
+	b, err := ConnectWithEndpoint(log, "test-token", api.URL+"/bot%s/%s", "error", nil, &groupChatId,
+		&m.RecordingSender{Status: m.GatewayStatus{Phone: "+62811", Connected: true, LoggedIn: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.SendLimits = &SendLimits{GlobalInterval: time.Millisecond, ChatInterval: time.Millisecond,
+		GroupInterval: time.Millisecond}
+
+	return b
+}
+
+// statusUpdate builds the webhook update of a /status command sent to the configured group
+func statusUpdate(updateId int) string {
+	return fmt.Sprintf(`{"update_id":%d,"message":{"message_id":5,"date":1,"text":"/status",
+		"from":{"id":7,"is_bot":false,"first_name":"agent"},
+		"chat":{"id":%d,"type":"supergroup","title":"agents"},
+		"entities":[{"type":"bot_command","offset":0,"length":7}]}}`, updateId, testGroupChatId)
+}
+
+// postUpdate posts the update to the webhook handler with the designated secret token
+func postUpdate(h http.Handler, secret, update string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/telegram", strings.NewReader(update))
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set(secretTokenHeader, secret)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestSetWebhookGeneratesSecret(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	b := newTestBot(t, api)
+
+	err := b.SetWebhook(WebhookConfig{URL: "https://example.com/telegram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := api.called("setWebhook")
+	if len(calls) != 1 {
+		t.Fatalf("expected setWebhook to be called once, got %d", len(calls))
+	}
+	if b.WebhookSecret() == "" || calls[0]["secret_token"] != b.WebhookSecret() {
+		t.Fatalf("expected the generated secret to be registered, got %q and %q", calls[0]["secret_token"],
+			b.WebhookSecret())
+	}
+}
+
+func TestWebhookHandlerRefusesWithoutSecret(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	b := newTestBot(t, api)
+	h := b.WebhookHandler(context.Background())
+
+	// no secret is configured, the handler must fail closed
+	rec := postUpdate(h, "", statusUpdate(1))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a configured secret, got %d", rec.Code)
+	}
+
+	b.SetWebhookSecret("secret")
+	for _, token := range []string{"", "wrong"} {
+		rec = postUpdate(h, token, statusUpdate(2))
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401 on token %q, got %d", token, rec.Code)
+		}
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if calls := api.called("sendMessage"); len(calls) != 0 {
+		t.Fatalf("the refused updates must not be processed, got %d replies", len(calls))
+	}
+}
+
+func TestWebhookHandlerAcksBeforeProcessing(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	api.block = make(chan struct{})
+	b := newTestBot(t, api)
+	b.SetWebhookSecret("secret")
+	h := b.WebhookHandler(context.Background())
+
+	// the reply is blocked by the fake API, the update is acknowledged anyway
+	done := make(chan int)
+	go func() { done <- postUpdate(h, "secret", statusUpdate(1)).Code }()
+	select {
+	case code := <-done:
+		if code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the update must be acknowledged before it is processed")
+	}
+
+	close(api.block)
+	calls := api.waitCalled(t, "sendMessage", 1)
+	if calls[0]["chat_id"] != fmt.Sprint(testGroupChatId) ||
+		!strings.Contains(calls[0]["text"], "WhatsApp gateway: OK") {
+		t.Fatalf("unexpected reply %+v", calls[0])
+	}
+}
+
+func TestWebhookHandlerDropsResentUpdates(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	b := newTestBot(t, api)
+	b.SetWebhookSecret("secret")
+	h := b.WebhookHandler(context.Background())
+
+	for i := 0; i < 3; i++ {
+		rec := postUpdate(h, "secret", statusUpdate(42))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+	}
+	rec := postUpdate(h, "secret", statusUpdate(43))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	api.waitCalled(t, "sendMessage", 2)
+	time.Sleep(50 * time.Millisecond)
+	if calls := api.called("sendMessage"); len(calls) != 2 {
+		t.Fatalf("expected each update to be processed once, got %d replies", len(calls))
+	}
+}
+
+func TestWebhookHandlerBadRequest(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	b := newTestBot(t, api)
+	b.SetWebhookSecret("secret")
+
+	rec := postUpdate(b.WebhookHandler(context.Background()), "secret", "not json")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestWebhookHandlerStopsWithContext(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	api.block = make(chan struct{})
+	b := newTestBot(t, api)
+	b.SetWebhookSecret("secret")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h := b.WebhookHandler(ctx)
+
+	// the first update is being processed, the second one is queued while the context is cancelled
+	for i := 1; i <= 2; i++ {
+		if rec := postUpdate(h, "secret", statusUpdate(i)); rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+	}
+	cancel()
+	close(api.block)
+
+	// the acknowledged updates are processed anyway
+	api.waitCalled(t, "sendMessage", 2)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		b.webhookMu.RLock()
+		closed := b.webhookClosed
+		b.webhookMu.RUnlock()
+		if closed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the webhook queue to be closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if rec := postUpdate(h, "secret", statusUpdate(3)); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 once stopped, got %d", rec.Code)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if calls := api.called("sendMessage"); len(calls) != 2 {
+		t.Fatalf("expected the refused update not to be processed, got %d replies", len(calls))
+	}
+}
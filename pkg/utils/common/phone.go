@@ -2,18 +2,28 @@ package common
 
 import (
 	"strings"
-	"unicode/utf8"
+
+	"github.com/ardihikaru/go-modules/pkg/utils/phone"
 )
 
-func SanitizePhone(phone string, withPlusSymbol *bool) string {
+// SanitizePhone sanitizes the phone number and formats it as E.164 (or Whatsapp JID user if `+` symbol is disabled)
+// if the phone number is invalid, it only removes the formatting characters
+//
+// Deprecated: use phone.Parse to parse, validate and format phone numbers
+func SanitizePhone(p string, withPlusSymbol *bool) string {
+	num, err := phone.Parse(p, phone.DefaultRegion)
+	if err != nil {
+		// replace minus symbol and spaces
+		p = strings.Replace(p, "-", "", -1)
+		p = strings.Replace(p, " ", "", -1)
+
+		return p
+	}
+
 	// removes `+` symbol if validation enabled
-	if withPlusSymbol != nil && !(*withPlusSymbol) && phone[0:1] == "+" {
-		_, i := utf8.DecodeRuneInString(phone)
-		phone = phone[i:]
+	if withPlusSymbol != nil && !(*withPlusSymbol) {
+		return num.JIDUser()
 	}
-	// replace minus symbol and spaces
-	phone = strings.Replace(phone, "-", "", -1)
-	phone = strings.Replace(phone, " ", "", -1)
 
-	return phone
+	return num.E164()
 }
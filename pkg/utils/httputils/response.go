@@ -43,6 +43,15 @@ const (
 	// LoginFailed is an application error code where user failed to log in
 	LoginFailed = 2003
 
+	// InvalidRecipient is an application error code where the designated recipient is invalid
+	InvalidRecipient = 2004
+
+	// RecipientNotOnWhatsapp is an application error code where the designated recipient is not available in Whatsapp
+	RecipientNotOnWhatsapp = 2005
+
+	// RecipientNotAllowed is an application error code where the designated recipient is rejected by the policy
+	RecipientNotAllowed = 2006
+
 	// BadRequest is an application error to represent bad request
 	BadRequest = 3001
 
@@ -66,6 +75,9 @@ const (
 
 	// DeleteDataFailed is an application error to represent that delete process failed
 	DeleteDataFailed = 4003
+
+	// SendMessageFailed is an application error to represent that sending message process failed
+	SendMessageFailed = 4004
 )
 
 // responseText is list of error test for each application-level error code
@@ -76,9 +88,12 @@ var responseText = map[int]string{
 	InvalidOrderQuery:           "invalid order in URL parameters",
 	InvalidSortQuery:            "invalid sort in URL parameters",
 
-	InputValidationError: "got input validation error",
-	UnauthorizedAccess:   "identity is unauthorized to access this API",
-	LoginFailed:          "invalid login data",
+	InputValidationError:   "got input validation error",
+	UnauthorizedAccess:     "identity is unauthorized to access this API",
+	LoginFailed:            "invalid login data",
+	InvalidRecipient:       "invalid recipient",
+	RecipientNotOnWhatsapp: "recipient is not available in Whatsapp",
+	RecipientNotAllowed:    "recipient is not allowed by the recipient policy",
 
 	BadRequest:           "bad request",
 	FailedToFetchData:    "failed to fetch data from the database",
@@ -86,9 +101,10 @@ var responseText = map[int]string{
 	InvalidOffsetValue:   "invalid offset value",
 	InvalidURLParameters: "failed to extract URL parameters",
 
-	CreateDataFailed: "insert process failed",
-	UpdateDataFailed: "update process failed",
-	DeleteDataFailed: "delete process failed",
+	CreateDataFailed:  "insert process failed",
+	UpdateDataFailed:  "update process failed",
+	DeleteDataFailed:  "delete process failed",
+	SendMessageFailed: "send message process failed",
 }
 
 // ResponseText returns a text for the HTTP status code in the application level.
@@ -0,0 +1,229 @@
+// Package phone provides functions to parse, validate and format phone numbers (E.164)
+package phone
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultRegion defines the region used to parse numbers written in the national format
+const DefaultRegion = "ID"
+
+const (
+	// maxDigits defines the maximum digits of a phone number (calling code included), based on E.164
+	maxDigits = 15
+
+	// minNationalDigits and maxNationalDigits define the generic national number length of unsupported regions
+	minNationalDigits = 4
+	maxNationalDigits = 14
+)
+
+var (
+	ErrEmpty              = errors.New("phone number is empty")
+	ErrInvalidCharacter   = errors.New("phone number contains invalid character(s)")
+	ErrUnknownRegion      = errors.New("unknown default region")
+	ErrInvalidCallingCode = errors.New("invalid country calling code")
+	ErrTooShort           = errors.New("phone number is too short")
+	ErrTooLong            = errors.New("phone number is too long")
+)
+
+// Number defines a parsed phone number
+type Number struct {
+	// CallingCode is the country calling code, e.g. "62"
+	CallingCode string
+
+	// NationalNumber is the national significant number (without trunk prefix), e.g. "81234567890"
+	NationalNumber string
+
+	// Region is the ISO 3166-1 alpha-2 region code, e.g. "ID". It is empty if the region is not supported
+	Region string
+}
+
+// Parse parses the phone number, numbers without an international prefix (`+` or `00`) are treated as follows:
+//   - numbers starting with the trunk prefix of the default region (e.g. `0812...` in Indonesia) are national numbers
+//   - numbers starting with the calling code of the default region (e.g. `62812...`) are international numbers
+//     without the `+` symbol, as Whatsapp JID users are
+//   - other numbers are national numbers without the trunk prefix (e.g. `812...`) if they are valid in the default
+//     region, otherwise international numbers without the `+` symbol
+//
+// Hence, numbers of other regions should be prefixed with `+` (see FromJIDUser), e.g. `81234567890` is parsed as
+// an Indonesian number rather than a Japanese one.
+func Parse(raw, defaultRegion string) (*Number, error) {
+	digits, international, err := clean(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if !international {
+		r, ok := regions[strings.ToUpper(defaultRegion)]
+		if !ok {
+			return nil, ErrUnknownRegion
+		}
+
+		// national format, e.g. 0812xxxx -> +62812xxxx
+		if r.TrunkPrefix != "" && strings.HasPrefix(digits, r.TrunkPrefix) {
+			return build(r.CallingCode, strings.TrimPrefix(digits, r.TrunkPrefix))
+		}
+
+		// national format without the trunk prefix, e.g. 812xxxx -> +62812xxxx
+		if !strings.HasPrefix(digits, r.CallingCode) {
+			if num, err := build(r.CallingCode, digits); err == nil {
+				return num, nil
+			}
+		}
+	}
+
+	// international format, finds the calling code (1 to 3 digits)
+	for i := 1; i <= 3 && i < len(digits); i++ {
+		if callingCodes[digits[:i]] {
+			return build(digits[:i], digits[i:])
+		}
+	}
+
+	return nil, ErrInvalidCallingCode
+}
+
+// NormalizePrefix formats the leading digits of phone numbers as an E.164 prefix (e.g. `0812` -> `+62812`)
+// the international prefix and the trunk prefix are handled as in Parse, but the length is not validated
+func NormalizePrefix(raw, defaultRegion string) (string, error) {
+	digits, international, err := clean(raw)
+	if err != nil {
+		return "", err
+	}
+
+	if !international {
+		r, ok := regions[strings.ToUpper(defaultRegion)]
+		if !ok {
+			return "", ErrUnknownRegion
+		}
+
+		// national format, e.g. 0812 -> +62812
+		if r.TrunkPrefix != "" && strings.HasPrefix(digits, r.TrunkPrefix) {
+			return fmt.Sprintf("+%s%s", r.CallingCode, strings.TrimPrefix(digits, r.TrunkPrefix)), nil
+		}
+	}
+
+	return fmt.Sprintf("+%s", digits), nil
+}
+
+// Normalize parses the phone number and formats it as E.164 (e.g. `+6281234567890`)
+func Normalize(raw, defaultRegion string) (string, error) {
+	num, err := Parse(raw, defaultRegion)
+	if err != nil {
+		return "", err
+	}
+
+	return num.E164(), nil
+}
+
+// ToJIDUser parses the phone number and formats it as Whatsapp JID user (e.g. `6281234567890`)
+func ToJIDUser(raw, defaultRegion string) (string, error) {
+	num, err := Parse(raw, defaultRegion)
+	if err != nil {
+		return "", err
+	}
+
+	return num.JIDUser(), nil
+}
+
+// E164 formats the phone number as E.164, e.g. `+6281234567890`
+func (n *Number) E164() string {
+	return fmt.Sprintf("+%s%s", n.CallingCode, n.NationalNumber)
+}
+
+// FromJIDUser formats the Whatsapp JID user as E.164 (e.g. `6281234567890` -> `+6281234567890`),
+// so that it is not parsed as a national number of the default region
+func FromJIDUser(user string) string {
+	return fmt.Sprintf("+%s", strings.TrimPrefix(user, "+"))
+}
+
+// JIDUser formats the phone number as Whatsapp JID user, e.g. `6281234567890`
+func (n *Number) JIDUser() string {
+	return n.CallingCode + n.NationalNumber
+}
+
+// National formats the phone number in the national format, e.g. `081234567890`
+// numbers of unsupported regions are formatted as E.164
+func (n *Number) National() string {
+	r, ok := regions[n.Region]
+	if !ok {
+		return n.E164()
+	}
+
+	return r.TrunkPrefix + n.NationalNumber
+}
+
+// String formats the phone number as E.164
+func (n *Number) String() string {
+	return n.E164()
+}
+
+// clean removes the formatting characters and the international prefix
+func clean(raw string) (string, bool, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", false, ErrEmpty
+	}
+
+	international := false
+	if strings.HasPrefix(raw, "+") {
+		international = true
+		raw = raw[1:]
+	}
+
+	var sb strings.Builder
+	for _, c := range raw {
+		switch {
+		case c >= '0' && c <= '9':
+			sb.WriteRune(c)
+		case c == ' ' || c == '-' || c == '.' || c == '(' || c == ')' || c == '/':
+			// ignores formatting characters
+		default:
+			return "", false, ErrInvalidCharacter
+		}
+	}
+
+	digits := sb.String()
+	if !international && strings.HasPrefix(digits, "00") {
+		international = true
+		digits = digits[2:]
+	}
+	if digits == "" {
+		return "", false, ErrEmpty
+	}
+
+	return digits, international, nil
+}
+
+// build validates the length of the phone number and builds it
+func build(callingCode, nationalNumber string) (*Number, error) {
+	if !callingCodes[callingCode] {
+		return nil, ErrInvalidCallingCode
+	}
+
+	regionCode, r := regionByCallingCode(callingCode)
+
+	minLength, maxLength := minNationalDigits, maxNationalDigits
+	if r != nil {
+		minLength, maxLength = r.MinLength, r.MaxLength
+
+		// some people write the trunk prefix after the calling code, e.g. +62 0812xxxx
+		if r.TrunkPrefix != "" && strings.HasPrefix(nationalNumber, r.TrunkPrefix) {
+			nationalNumber = strings.TrimPrefix(nationalNumber, r.TrunkPrefix)
+		}
+	}
+
+	if len(nationalNumber) < minLength {
+		return nil, ErrTooShort
+	}
+	if len(nationalNumber) > maxLength || len(callingCode)+len(nationalNumber) > maxDigits {
+		return nil, ErrTooLong
+	}
+
+	return &Number{
+		CallingCode:    callingCode,
+		NationalNumber: nationalNumber,
+		Region:         regionCode,
+	}, nil
+}
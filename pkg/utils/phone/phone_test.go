@@ -0,0 +1,52 @@
+package phone
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	for raw, want := range map[string]string{
+		"081234567890":      "+6281234567890",
+		"81234567890":       "+6281234567890",
+		"6281234567890":     "+6281234567890",
+		"+62 812-3456-7890": "+6281234567890",
+		"0081234567890":     "+81234567890",
+		"+81234567890":      "+81234567890",
+		"+60123456789":      "+60123456789",
+	} {
+		got, err := Normalize(raw, "ID")
+		if err != nil || got != want {
+			t.Fatalf("expected [%s] to be %s, got %s, %v", raw, want, got, err)
+		}
+	}
+
+	// too long for a national number, hence parsed as an international one
+	got, err := Normalize("819012345678901", "ID")
+	if err == nil {
+		t.Fatalf("expected an invalid number, got %s", got)
+	}
+
+	_, err = Normalize("0812", "XX")
+	if !errors.Is(err, ErrUnknownRegion) {
+		t.Fatalf("expected ErrUnknownRegion, got %v", err)
+	}
+}
+
+func TestFromJIDUser(t *testing.T) {
+	for _, user := range []string{"81234567890", "+81234567890"} {
+		got, err := Normalize(FromJIDUser(user), "ID")
+		if err != nil || got != "+81234567890" {
+			t.Fatalf("expected the JID user [%s] to keep its calling code, got %s, %v", user, got, err)
+		}
+	}
+}
+
+func TestNormalizePrefix(t *testing.T) {
+	for raw, want := range map[string]string{"0812": "+62812", "+62812": "+62812", "62812": "+62812", "0060": "+60"} {
+		got, err := NormalizePrefix(raw, "ID")
+		if err != nil || got != want {
+			t.Fatalf("expected [%s] to be %s, got %s, %v", raw, want, got, err)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package watelebot
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LabelResolver resolves the labels of the whatsapp contacts, which are matched by the telegram routing rules
+// (see telegrambot.Route), e.g. the segments kept by a CRM
+//
+// whatsmeow does not expose the labels of whatsapp business, hence they are resolved outside the session.
+type LabelResolver interface {
+	// Labels returns the labels of the designated phone (JID user, e.g. 6281234567890), nil if there is none
+	Labels(phone string) ([]string, error)
+}
+
+// StaticLabels resolves the labels from a fixed map of the phone to its labels,
+// the phone is either the JID user or E.164, e.g. "6281234567890" or "+6281234567890"
+type StaticLabels map[string][]string
+
+// Labels returns the labels of the designated phone
+func (l StaticLabels) Labels(phone string) ([]string, error) {
+	phone = strings.TrimPrefix(phone, "+")
+	if labels, ok := l[phone]; ok {
+		return labels, nil
+	}
+
+	return l["+"+phone], nil
+}
+
+// labels resolves the labels of the designated phone, if the resolver exists
+// a failure is logged, and the message is routed without the labels
+func (wb *WhatsappBot) labels(phone string) []string {
+	if wb.Labels == nil {
+		return nil
+	}
+
+	labels, err := wb.Labels.Labels(phone)
+	if err != nil {
+		wb.log.Warn(fmt.Sprintf("failed to resolve the labels of [%s] -> %s", phone, err.Error()))
+		return nil
+	}
+
+	return labels
+}
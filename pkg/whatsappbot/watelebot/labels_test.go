@@ -0,0 +1,48 @@
+package watelebot
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ardihikaru/go-modules/pkg/logger"
+)
+
+// failingLabels fails every resolution
+type failingLabels struct{}
+
+func (failingLabels) Labels(string) ([]string, error) {
+	return nil, errors.New("crm is down")
+}
+
+func TestLabels(t *testing.T) {
+	log, err := logger.New("error", "console")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wb := &WhatsappBot{log: log}
+
+	if labels := wb.labels("6281234567890"); labels != nil {
+		t.Fatalf("expected no label without the resolver, got %v", labels)
+	}
+
+	wb.Labels = StaticLabels{
+		"6281234567890":  {"vip"},
+		"+6289876543210": {"reseller", "jakarta"},
+	}
+	for phone, want := range map[string][]string{
+		"6281234567890":  {"vip"},
+		"+6281234567890": {"vip"},
+		"6289876543210":  {"reseller", "jakarta"},
+		"6280000000000":  nil,
+	} {
+		if labels := wb.labels(phone); !reflect.DeepEqual(labels, want) {
+			t.Fatalf("expected the labels of [%s] to be %v, got %v", phone, want, labels)
+		}
+	}
+
+	wb.Labels = failingLabels{}
+	if labels := wb.labels("6281234567890"); labels != nil {
+		t.Fatalf("expected no label on a failure, got %v", labels)
+	}
+}
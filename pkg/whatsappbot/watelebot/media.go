@@ -0,0 +1,88 @@
+package watelebot
+
+import (
+	"fmt"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+
+	tgBot "github.com/ardihikaru/go-modules/pkg/telegrambot"
+)
+
+// maxTelegramUpload defines the maximum file size which can be uploaded by a telegram bot (50 MB)
+const maxTelegramUpload = 50 * 1024 * 1024
+
+// downloadableMedia defines the downloadable media with its file length
+type downloadableMedia interface {
+	whatsmeow.DownloadableMessage
+	GetFileLength() uint64
+	GetMimetype() string
+}
+
+// extractMedia extracts the media of the captured message and its caption
+// it returns nil media if the message has no (supported) media
+func (wb *WhatsappBot) extractMedia(msg *waProto.Message) (*tgBot.Media, string, error) {
+	switch {
+	case msg.GetImageMessage() != nil:
+		img := msg.GetImageMessage()
+		media, err := wb.download(tgBot.MediaImage, img, "")
+		return media, img.GetCaption(), err
+
+	case msg.GetAudioMessage() != nil:
+		audio := msg.GetAudioMessage()
+		mediaType := tgBot.MediaAudio
+		if audio.GetPtt() {
+			mediaType = tgBot.MediaVoice
+		}
+		media, err := wb.download(mediaType, audio, "")
+		return media, "", err
+
+	case msg.GetVideoMessage() != nil:
+		video := msg.GetVideoMessage()
+		media, err := wb.download(tgBot.MediaVideo, video, "")
+		return media, video.GetCaption(), err
+
+	case msg.GetDocumentMessage() != nil:
+		doc := msg.GetDocumentMessage()
+		media, err := wb.download(tgBot.MediaDocument, doc, doc.GetFileName())
+		return media, doc.GetCaption(), err
+
+	case msg.GetStickerMessage() != nil:
+		media, err := wb.download(tgBot.MediaSticker, msg.GetStickerMessage(), "")
+		return media, "", err
+
+	case msg.GetLocationMessage() != nil:
+		location := msg.GetLocationMessage()
+		caption := location.GetName()
+		if location.GetAddress() != "" {
+			caption = fmt.Sprintf("%s\n%s", caption, location.GetAddress())
+		}
+		return &tgBot.Media{
+			Type:      tgBot.MediaLocation,
+			Latitude:  location.GetDegreesLatitude(),
+			Longitude: location.GetDegreesLongitude(),
+		}, caption, nil
+
+	default:
+		return nil, "", nil
+	}
+}
+
+// download downloads the media from the whatsapp server
+func (wb *WhatsappBot) download(mediaType string, msg downloadableMedia, fileName string) (*tgBot.Media, error) {
+	if msg.GetFileLength() > maxTelegramUpload {
+		return nil, fmt.Errorf("the [%s] file is too large to forward (%d bytes)", mediaType, msg.GetFileLength())
+	}
+
+	data, err := wb.Client.Download(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tgBot.Media{
+		Type:     mediaType,
+		Data:     data,
+		FileName: fileName,
+		MimeType: msg.GetMimetype(),
+	}, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/mdp/qrterminal"
@@ -16,6 +17,7 @@ import (
 	"google.golang.org/protobuf/proto"
 
 	"github.com/ardihikaru/go-modules/pkg/logger"
+	"github.com/ardihikaru/go-modules/pkg/schedule"
 	tgBot "github.com/ardihikaru/go-modules/pkg/telegrambot"
 )
 
@@ -24,8 +26,17 @@ type DataPasser struct {
 }
 
 type WhatsappBot struct {
-	TelegramBot    *tgBot.TelegramBot
-	Client         *whatsmeow.Client
+	TelegramBot *tgBot.TelegramBot
+	Client      *whatsmeow.Client
+
+	// AutoReply replies the messages received out of the business hours if exists,
+	// the messages are still forwarded to telegram
+	AutoReply *schedule.AutoReplier
+
+	// Labels resolves the labels of the whatsapp contacts if exists, which are matched by the telegram routing
+	// rules, otherwise the routes with labels never match
+	Labels LabelResolver
+
 	log            *logger.Logger
 	eventHandlerID uint32
 }
@@ -103,13 +114,65 @@ func (wb *WhatsappBot) eventHandler(evt interface{}) {
 		// monkey patch! somethimes the text is not in the Conversation, but in the ExtendedTextMessage
 		// e.g. from Albert / Taiwan
 		if message == "" {
-			message = *v.Message.ExtendedTextMessage.Text
+			message = v.Message.GetExtendedTextMessage().GetText()
+		}
+
+		// downloads the media (if any), the caption is forwarded as the message
+		media, caption, err := wb.extractMedia(v.Message)
+		if err != nil {
+			wb.log.Warn(fmt.Sprintf("failed to download the media of message [%s] -> %s", msgId, err.Error()))
+			message = fmt.Sprintf("[media can not be forwarded: %s]", err.Error())
+		} else if media != nil {
+			message = caption
+		}
+
+		// ignores messages without any content (e.g. protocol messages)
+		if message == "" && media == nil {
+			return
 		}
 
 		wb.log.Debug(fmt.Sprintf("**** [%s][%s] Received a message from [%s] (%s)! -> '%s'\n\n",
 			ts, msgId, name, phone, message))
 
+		// out of the business hours, the customer is told when to expect the answer
+		if !v.Info.IsFromMe && !v.Info.IsGroup {
+			wb.autoReply(v.Info.Chat, phone, name, ts)
+		}
+
 		// sends to telegram messenger
-		wb.TelegramBot.SendTextMsg(ts, phone, msgId, name, message)
+		wb.TelegramBot.ForwardMsg(&tgBot.WhatsappMessage{
+			Session:   wb.session(),
+			ChatJID:   v.Info.Chat.String(),
+			MsgId:     msgId,
+			Phone:     phone,
+			Name:      name,
+			Message:   message,
+			Timestamp: ts,
+			Media:     media,
+			Labels:    wb.labels(phone),
+		})
 	}
 }
+
+// autoReply sends the out-of-hours auto-reply, if enabled and not sent yet in the current closed window
+func (wb *WhatsappBot) autoReply(chat types.JID, phone, name string, ts time.Time) {
+	if wb.AutoReply == nil {
+		return
+	}
+
+	_, err := wb.AutoReply.Reply(chat.String(), name, ts, func(reply string) error {
+		return wb.SendMsg(chat, phone, reply)
+	})
+	if err != nil {
+		wb.log.Warn(fmt.Sprintf("failed to send the auto-reply to [%s] -> %s", phone, err.Error()))
+	}
+}
+
+// session returns the phone owner of this whatsapp session
+func (wb *WhatsappBot) session() string {
+	if wb.Client.Store.ID == nil {
+		return ""
+	}
+
+	return wb.Client.Store.ID.User
+}
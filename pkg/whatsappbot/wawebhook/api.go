@@ -0,0 +1,225 @@
+package wawebhook
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.uber.org/zap"
+
+	"github.com/ardihikaru/go-modules/pkg/utils/httputils"
+)
+
+// Handler defines the REST API handler to trigger the whatsapp bot
+type Handler struct {
+	Bot *WaBot
+}
+
+// NewHandler builds the REST API handler of the designated whatsapp bot
+func NewHandler(bot *WaBot) *Handler {
+	return &Handler{Bot: bot}
+}
+
+// Routes builds the REST API routes
+func (h *Handler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Post("/messages", h.SendMessage)
+	r.Post("/reactions", h.SendReaction)
+	r.Post("/revokes", h.RevokeMessage)
+	r.Get("/status", h.GetStatus)
+	r.Get("/contacts/{phone}", h.GetContact)
+
+	return r
+}
+
+// SendMessage sends a text or an image message to the designated phone
+func (h *Handler) SendMessage(w http.ResponseWriter, r *http.Request) {
+	var payload MessagePayload
+	if !h.extractPayload(w, r, &payload) {
+		return
+	}
+
+	err := payload.Validate()
+	if err != nil {
+		renderValidationErr(w, r, err)
+		return
+	}
+	payload.Sanitize()
+
+	recipient, err := h.Bot.ValidateAndGetRecipient(payload.To, true)
+	if err != nil {
+		renderRecipientErr(w, r, err)
+		return
+	}
+
+	// the image caption has higher priority than the message, if exists
+	msgObj := ReplyMessage{
+		Message:       payload.Message,
+		WithImage:     payload.ImageFileName != "",
+		ImageFileName: payload.ImageFileName,
+	}
+	if msgObj.WithImage && payload.ImageCaption != "" {
+		msgObj.Message = payload.ImageCaption
+	}
+
+	// quotes the designated message, if requested
+	var ctxInfo *waProto.ContextInfo
+	if payload.ReplyToMsgId != "" {
+		ctxInfo = h.Bot.quoteContextInfo(*recipient, payload.ReplyToMsgId)
+	}
+
+	if payload.Media != nil {
+		payload.MsgId, err = h.Bot.sendMediaMsg(*recipient, payload.Media, payload.Message, ctxInfo)
+	} else {
+		payload.MsgId, err = h.Bot.sendMsgAndWait(*recipient, msgObj, ctxInfo)
+	}
+	if err != nil {
+		h.Bot.Log.Error("failed to send the message", zap.Error(err))
+		renderSendErr(w, r, err)
+		return
+	}
+
+	// the media data is not echoed back
+	if payload.Media != nil {
+		payload.Media = &MediaPayload{Type: payload.Media.Type, FileName: payload.Media.FileName,
+			MimeType: payload.Media.MimeType}
+	}
+
+	_ = httputils.RenderOKResponse(w, r, httputils.Response{
+		Data:        payload,
+		MessageText: "message has been sent",
+	})
+}
+
+// SendReaction reacts to the designated message
+func (h *Handler) SendReaction(w http.ResponseWriter, r *http.Request) {
+	var payload ReactionPayload
+	if !h.extractPayload(w, r, &payload) {
+		return
+	}
+
+	err := payload.Validate()
+	if err != nil {
+		renderValidationErr(w, r, err)
+		return
+	}
+	payload.Sanitize()
+
+	recipient, err := h.Bot.ValidateAndGetRecipient(payload.To, true)
+	if err != nil {
+		renderRecipientErr(w, r, err)
+		return
+	}
+
+	// in a private chat, the message is sent either by this device or by the recipient
+	sender := *recipient
+	if payload.FromMe && h.Bot.Client.Store.ID != nil {
+		sender = *h.Bot.Client.Store.ID
+	}
+
+	err = h.Bot.SendReaction(*recipient, sender, payload.MsgId, payload.Reaction)
+	if err != nil {
+		h.Bot.Log.Error("failed to send the reaction", zap.Error(err))
+		renderSendErr(w, r, err)
+		return
+	}
+
+	_ = httputils.RenderOKResponse(w, r, httputils.Response{
+		Data:        payload,
+		MessageText: "reaction has been sent",
+	})
+}
+
+// RevokeMessage revokes the designated message sent by this device
+func (h *Handler) RevokeMessage(w http.ResponseWriter, r *http.Request) {
+	var payload RevokePayload
+	if !h.extractPayload(w, r, &payload) {
+		return
+	}
+
+	err := payload.Validate()
+	if err != nil {
+		renderValidationErr(w, r, err)
+		return
+	}
+	payload.Sanitize()
+
+	recipient, err := h.Bot.ValidateAndGetRecipient(payload.To, true)
+	if err != nil {
+		renderRecipientErr(w, r, err)
+		return
+	}
+
+	err = h.Bot.RevokeMessage(*recipient, payload.MsgId)
+	if err != nil {
+		h.Bot.Log.Error("failed to revoke the message", zap.Error(err))
+		renderSendErr(w, r, err)
+		return
+	}
+
+	_ = httputils.RenderOKResponse(w, r, httputils.Response{
+		Data:        payload,
+		MessageText: "message has been revoked",
+	})
+}
+
+// GetStatus returns the status of the whatsapp session
+func (h *Handler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	_ = httputils.RenderOKResponse(w, r, httputils.Response{
+		Data: h.Bot.Status(),
+	})
+}
+
+// GetContact returns the contact information of the designated phone
+func (h *Handler) GetContact(w http.ResponseWriter, r *http.Request) {
+	contact, err := h.Bot.GetContactInfo(chi.URLParam(r, "phone"))
+	if err != nil {
+		renderRecipientErr(w, r, err)
+		return
+	}
+
+	_ = httputils.RenderOKResponse(w, r, httputils.Response{
+		Data: contact,
+	})
+}
+
+// extractPayload extracts the JSON request body and renders the error response on failure
+func (h *Handler) extractPayload(w http.ResponseWriter, r *http.Request, destType interface{}) bool {
+	code, httpStatus, err := httputils.GetJsonBody(r.Body, destType)
+	if err != nil {
+		httputils.RenderErrResponse(w, r, httputils.ResponseText("", code), int64(code), httpStatus, err)
+		return false
+	}
+
+	return true
+}
+
+// renderValidationErr renders the input validation error response
+func renderValidationErr(w http.ResponseWriter, r *http.Request, err error) {
+	httputils.RenderErrResponse(w, r, httputils.ResponseText("", httputils.InputValidationError),
+		httputils.InputValidationError, http.StatusBadRequest, err)
+}
+
+// renderRecipientErr renders the recipient validation error response, based on the reason
+func renderRecipientErr(w http.ResponseWriter, r *http.Request, err error) {
+	appErrCode, httpStatusCode := httputils.InvalidRecipient, http.StatusInternalServerError
+
+	switch {
+	case errors.Is(err, ErrInvalidPhone):
+		appErrCode, httpStatusCode = httputils.InputValidationError, http.StatusBadRequest
+	case errors.Is(err, ErrNotOnWhatsapp):
+		appErrCode, httpStatusCode = httputils.RecipientNotOnWhatsapp, http.StatusNotFound
+	case errors.Is(err, ErrNotInContacts), errors.Is(err, ErrRecipientDenied), errors.Is(err, ErrRecipientNotAllowed):
+		appErrCode, httpStatusCode = httputils.RecipientNotAllowed, http.StatusForbidden
+	}
+
+	httputils.RenderErrResponse(w, r, httputils.ResponseText("", appErrCode), int64(appErrCode), httpStatusCode, err)
+}
+
+// renderSendErr renders the failed sending response
+func renderSendErr(w http.ResponseWriter, r *http.Request, err error) {
+	httputils.RenderErrResponse(w, r, httputils.ResponseText("", httputils.SendMessageFailed),
+		httputils.SendMessageFailed, http.StatusInternalServerError, err)
+}
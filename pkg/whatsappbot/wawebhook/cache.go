@@ -0,0 +1,72 @@
+package wawebhook
+
+import (
+	"sync"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// defaultMsgCacheSize defines the maximum number of recent messages kept in memory
+const defaultMsgCacheSize = 1000
+
+// defaultOnWhatsappCacheSize defines the maximum number of IsOnWhatsApp results kept in memory
+const defaultOnWhatsappCacheSize = 1000
+
+// cachedMessage defines the captured message information required to quote it later
+type cachedMessage struct {
+	Chat    types.JID
+	Sender  types.JID
+	Message *waProto.Message
+}
+
+// messageCache stores the recently captured messages, the oldest one is evicted first
+type messageCache struct {
+	mu    sync.Mutex
+	size  int
+	order []string
+	msgs  map[string]cachedMessage
+}
+
+// newMessageCache builds a new message cache
+func newMessageCache(size int) *messageCache {
+	return &messageCache{
+		size: size,
+		msgs: make(map[string]cachedMessage),
+	}
+}
+
+// put stores the captured message
+func (c *messageCache) put(msgId string, msg cachedMessage) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.msgs[msgId]; !exists {
+		c.order = append(c.order, msgId)
+	}
+	c.msgs[msgId] = msg
+
+	// evicts the oldest messages
+	for len(c.order) > c.size {
+		delete(c.msgs, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// get gets the captured message
+func (c *messageCache) get(msgId string) (cachedMessage, bool) {
+	if c == nil {
+		return cachedMessage{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	msg, ok := c.msgs[msgId]
+
+	return msg, ok
+}
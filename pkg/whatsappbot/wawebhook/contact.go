@@ -0,0 +1,57 @@
+package wawebhook
+
+import (
+	"fmt"
+
+	"go.mau.fi/whatsmeow/types"
+
+	ph "github.com/ardihikaru/go-modules/pkg/utils/phone"
+)
+
+// Status returns the status of this whatsapp session
+func (wb *WaBot) Status() StatusPayload {
+	return StatusPayload{
+		Phone:     wb.Phone,
+		Connected: wb.Client.IsConnected(),
+		LoggedIn:  wb.Client.IsLoggedIn(),
+	}
+}
+
+// GetContactInfo gets the contact information of the designated phone
+// unlike ValidateAndGetRecipient, the recipient policy is not applied here
+func (wb *WaBot) GetContactInfo(rawPhone string) (*ContactPayload, error) {
+	phone, err := ph.Normalize(rawPhone, ph.DefaultRegion)
+	if err != nil {
+		return nil, &RecipientError{Phone: rawPhone, Err: fmt.Errorf("%w: %s", ErrInvalidPhone, err.Error())}
+	}
+
+	jid, isIn, err := wb.lookupOnWhatsapp(phone)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ContactPayload{Phone: phone, OnWhatsapp: isIn}
+	if !isIn {
+		return info, nil
+	}
+	info.JID = jid.String()
+
+	contact, err := wb.Client.Store.Contacts.GetContact(jid)
+	if err != nil {
+		wb.Log.Warn(fmt.Sprintf("failed to get contact of this number [%s]", phone))
+	} else {
+		info.InContacts = inContactList(contact)
+		info.FullName = contact.FullName
+		info.FirstName = contact.FirstName
+		info.PushName = contact.PushName
+		info.BusinessName = contact.BusinessName
+	}
+
+	// the about text is optional, since it may be hidden by the user
+	userInfo, err := wb.Client.GetUserInfo([]types.JID{jid})
+	if err == nil {
+		info.About = userInfo[jid].Status
+	}
+
+	return info, nil
+}
@@ -0,0 +1,173 @@
+package wawebhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// media types supported by SendMediaMsg
+const (
+	MediaImage    = "image"
+	MediaVideo    = "video"
+	MediaAudio    = "audio"
+	MediaVoice    = "voice"
+	MediaDocument = "document"
+)
+
+// MediaPayload defines the media to send, the data is base64 encoded in JSON
+type MediaPayload struct {
+	Type     string `json:"type"`
+	FileName string `json:"file_name,omitempty"`
+	MimeType string `json:"mimetype,omitempty"`
+	Data     []byte `json:"data"`
+}
+
+// Validate validates media payload
+func (p *MediaPayload) Validate() error {
+	if _, ok := whatsappMediaType(p.Type); !ok {
+		return fmt.Errorf("unsupported media type [%s]", p.Type)
+	}
+	if len(p.Data) == 0 {
+		return fmt.Errorf("media data is required")
+	}
+
+	return nil
+}
+
+// whatsappMediaType maps the media type with the whatsapp upload type
+func whatsappMediaType(mediaType string) (whatsmeow.MediaType, bool) {
+	switch mediaType {
+	case MediaImage:
+		return whatsmeow.MediaImage, true
+	case MediaVideo:
+		return whatsmeow.MediaVideo, true
+	case MediaAudio, MediaVoice:
+		return whatsmeow.MediaAudio, true
+	case MediaDocument:
+		return whatsmeow.MediaDocument, true
+	default:
+		return "", false
+	}
+}
+
+// SendMediaMsg uploads and sends the media message to designated whatsapp number
+func (wb *WaBot) SendMediaMsg(recipient types.JID, media *MediaPayload, caption string) error {
+	_, err := wb.sendMediaMsg(recipient, media, caption, nil)
+	return err
+}
+
+// sendMediaMsg uploads and sends the media message to designated whatsapp number,
+// and returns the ID of the sent message
+func (wb *WaBot) sendMediaMsg(recipient types.JID, media *MediaPayload, caption string,
+	ctxInfo *waProto.ContextInfo) (string, error) {
+	err := media.Validate()
+	if err != nil {
+		return "", err
+	}
+
+	uploaded, err := wb.UploadMediaToWhatsapp(media)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := wb.Client.SendMessage(context.Background(), recipient, buildMediaMsg(media, uploaded, caption, ctxInfo))
+	if err != nil {
+		wb.Log.Debug(fmt.Sprintf("[to:%s] failed to send [%s] message", recipient.User, media.Type))
+		return "", err
+	} else {
+		wb.Log.Debug(fmt.Sprintf("[to:%s] message sent (server timestamp: %s)", recipient.User, resp.Timestamp))
+	}
+
+	return resp.ID, nil
+}
+
+// UploadMediaToWhatsapp uploads the media to Whatsapp server
+func (wb *WaBot) UploadMediaToWhatsapp(media *MediaPayload) (*whatsmeow.UploadResponse, error) {
+	mediaType, ok := whatsappMediaType(media.Type)
+	if !ok {
+		return nil, fmt.Errorf("unsupported media type [%s]", media.Type)
+	}
+
+	uploaded, err := wb.Client.Upload(context.Background(), media.Data, mediaType)
+	if err != nil {
+		wb.Log.Debug(fmt.Sprintf("[type:%s] failed to upload file", media.Type))
+		return nil, err
+	}
+
+	return &uploaded, nil
+}
+
+// buildMediaMsg builds the whatsapp message of the uploaded media
+func buildMediaMsg(media *MediaPayload, uploaded *whatsmeow.UploadResponse, caption string,
+	ctxInfo *waProto.ContextInfo) *waProto.Message {
+	mimeType := media.MimeType
+	if mimeType == "" {
+		mimeType = http.DetectContentType(media.Data)
+	}
+	fileLength := uint64(len(media.Data))
+
+	switch media.Type {
+	case MediaImage:
+		return &waProto.Message{ImageMessage: &waProto.ImageMessage{
+			Caption:       proto.String(caption),
+			Url:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			Mimetype:      proto.String(mimeType),
+			FileEncSha256: uploaded.FileEncSHA256,
+			FileSha256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(fileLength),
+			ContextInfo:   ctxInfo,
+		}}
+	case MediaVideo:
+		return &waProto.Message{VideoMessage: &waProto.VideoMessage{
+			Caption:       proto.String(caption),
+			Url:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			Mimetype:      proto.String(mimeType),
+			FileEncSha256: uploaded.FileEncSHA256,
+			FileSha256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(fileLength),
+			ContextInfo:   ctxInfo,
+		}}
+	case MediaAudio, MediaVoice:
+		// audio messages have no caption
+		return &waProto.Message{AudioMessage: &waProto.AudioMessage{
+			Url:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			Mimetype:      proto.String(mimeType),
+			FileEncSha256: uploaded.FileEncSHA256,
+			FileSha256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(fileLength),
+			Ptt:           proto.Bool(media.Type == MediaVoice),
+			ContextInfo:   ctxInfo,
+		}}
+	default:
+		fileName := media.FileName
+		if fileName == "" {
+			fileName = "file"
+		}
+
+		return &waProto.Message{DocumentMessage: &waProto.DocumentMessage{
+			Caption:       proto.String(caption),
+			Title:         proto.String(fileName),
+			FileName:      proto.String(fileName),
+			Url:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			Mimetype:      proto.String(mimeType),
+			FileEncSha256: uploaded.FileEncSHA256,
+			FileSha256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(fileLength),
+			ContextInfo:   ctxInfo,
+		}}
+	}
+}
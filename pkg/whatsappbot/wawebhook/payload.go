@@ -1,24 +1,147 @@
 package wawebhook
 
-import "github.com/ardihikaru/go-modules/pkg/utils/common"
+import (
+	"fmt"
+
+	"github.com/ardihikaru/go-modules/pkg/utils/phone"
+)
 
 type MessagePayload struct {
-	From          string `json:"from"`
-	To            string `json:"to"`
-	Message       string `json:"message,omitempty"`
-	ImageFileName string `json:"image_filename,omitempty"`
-	ImageCaption  string `json:"image_caption,omitempty"`
+	From          string        `json:"from"`
+	To            string        `json:"to"`
+	Phone         string        `json:"phone,omitempty"` // an alias of `to`, as sent by the messenger
+	Message       string        `json:"message,omitempty"`
+	ImageFileName string        `json:"image_filename,omitempty"`
+	ImageCaption  string        `json:"image_caption,omitempty"`
+	ReplyToMsgId  string        `json:"reply_to_msg_id,omitempty"`
+	Media         *MediaPayload `json:"media,omitempty"`
+	MsgId         string        `json:"msg_id,omitempty"` // the ID of the sent message, set on the response
+}
+
+// ReactionPayload defines the payload to react to a message
+type ReactionPayload struct {
+	To       string `json:"to"`
+	MsgId    string `json:"msg_id"`
+	FromMe   bool   `json:"from_me,omitempty"`
+	Reaction string `json:"reaction"`
+}
+
+// RevokePayload defines the payload to revoke a sent message
+type RevokePayload struct {
+	To    string `json:"to"`
+	MsgId string `json:"msg_id"`
+}
+
+// validatePhone validates the phone number of the designated field
+func validatePhone(field, value string) error {
+	_, err := phone.Parse(value, phone.DefaultRegion)
+	if err != nil {
+		return fmt.Errorf("invalid %s phone: %w", field, err)
+	}
+
+	return nil
+}
+
+// sanitizePhone formats the phone number as E.164 (with `+` symbol), so that it is parsed the same way later
+// invalid phone numbers are kept as they are, since they are rejected by the validation
+func sanitizePhone(value string) string {
+	number, err := phone.Normalize(value, phone.DefaultRegion)
+	if err != nil {
+		return value
+	}
+
+	return number
 }
 
 // Validate validates message payload
 func (p *MessagePayload) Validate() error {
+	if err := validatePhone("recipient", p.recipient()); err != nil {
+		return err
+	}
+	if p.From != "" {
+		if err := validatePhone("sender", p.From); err != nil {
+			return err
+		}
+	}
+	if p.Media != nil {
+		return p.Media.Validate()
+	}
+	if p.Message == "" && p.ImageFileName == "" {
+		return fmt.Errorf("either message, image filename or media is required")
+	}
+
 	return nil
 }
 
 // Sanitize sanitizes message payload
 func (p *MessagePayload) Sanitize() {
-	plusSymbol := false
+	if p.From != "" {
+		p.From = sanitizePhone(p.From)
+	}
+	p.To = sanitizePhone(p.recipient())
+	p.Phone = ""
+}
+
+// recipient returns the recipient phone, `to` has higher priority than its alias (`phone`)
+func (p *MessagePayload) recipient() string {
+	if p.To != "" {
+		return p.To
+	}
+
+	return p.Phone
+}
+
+// Validate validates reaction payload
+// an empty reaction is allowed, since it removes the previously sent reaction
+func (p *ReactionPayload) Validate() error {
+	if err := validatePhone("recipient", p.To); err != nil {
+		return err
+	}
+	if p.MsgId == "" {
+		return fmt.Errorf("message ID is required")
+	}
+
+	return nil
+}
+
+// Sanitize sanitizes reaction payload
+func (p *ReactionPayload) Sanitize() {
+	p.To = sanitizePhone(p.To)
+}
+
+// Validate validates revoke payload
+func (p *RevokePayload) Validate() error {
+	if err := validatePhone("recipient", p.To); err != nil {
+		return err
+	}
+	if p.MsgId == "" {
+		return fmt.Errorf("message ID is required")
+	}
+
+	return nil
+}
+
+// Sanitize sanitizes revoke payload
+func (p *RevokePayload) Sanitize() {
+	p.To = sanitizePhone(p.To)
+}
+
+// StatusPayload defines the status of the whatsapp session
+type StatusPayload struct {
+	Phone     string `json:"phone"`
+	Connected bool   `json:"connected"`
+	LoggedIn  bool   `json:"logged_in"`
+}
 
-	p.From = common.SanitizePhone(p.From, &plusSymbol)
-	p.To = common.SanitizePhone(p.To, &plusSymbol)
+// ContactPayload defines the contact information of a whatsapp number
+type ContactPayload struct {
+	Phone        string `json:"phone"`
+	JID          string `json:"jid,omitempty"`
+	OnWhatsapp   bool   `json:"on_whatsapp"`
+	InContacts   bool   `json:"in_contacts"`
+	FullName     string `json:"full_name,omitempty"`
+	FirstName    string `json:"first_name,omitempty"`
+	PushName     string `json:"push_name,omitempty"`
+	BusinessName string `json:"business_name,omitempty"`
+	About        string `json:"about,omitempty"`
 }
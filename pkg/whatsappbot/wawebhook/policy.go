@@ -0,0 +1,229 @@
+package wawebhook
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+
+	ph "github.com/ardihikaru/go-modules/pkg/utils/phone"
+)
+
+var (
+	ErrInvalidPhone        = errors.New("invalid phone number")
+	ErrNotOnWhatsapp       = errors.New("this number is not available in Whatsapp")
+	ErrNotInContacts       = errors.New("this number is not on the contact list")
+	ErrRecipientDenied     = errors.New("this number is denied by the recipient policy")
+	ErrRecipientNotAllowed = errors.New("this number is not allowed by the recipient policy")
+)
+
+// RecipientError defines the error captured while validating the recipient
+// use errors.Is to check the reason, e.g. errors.Is(err, ErrNotOnWhatsapp)
+type RecipientError struct {
+	Phone string
+	Err   error
+}
+
+// Error returns the error message
+func (e *RecipientError) Error() string {
+	return fmt.Sprintf("recipient [%s]: %s", e.Phone, e.Err.Error())
+}
+
+// Unwrap returns the reason of the error
+func (e *RecipientError) Unwrap() error {
+	return e.Err
+}
+
+// RecipientPolicy defines the rules to validate the recipient of the outgoing messages of a session
+//
+// A pattern is either an explicit number (e.g. `+6281234567890`) or a prefix (e.g. `+62` or `+62812*`).
+// A pattern ending with `*`, or a pattern which is not a valid phone number, is treated as a prefix.
+// Local patterns (e.g. `0812*`) are normalized with the default region, as the recipients are.
+type RecipientPolicy struct {
+	// ContactsOnly only allows recipients who are on the contact list
+	ContactsOnly bool
+
+	// AutoAddContacts adds the sender of incoming messages to the contact list,
+	// so that they can be replied even if ContactsOnly is enabled
+	AutoAddContacts bool
+
+	// Allow lists the allowed patterns, an empty list allows every recipient
+	Allow []string
+
+	// Deny lists the denied patterns, it has higher priority than Allow
+	Deny []string
+
+	// OnWhatsappTTL defines how long the IsOnWhatsApp result is cached, zero disables the cache
+	OnWhatsappTTL time.Duration
+}
+
+// check checks the phone number (formatted as E.164) against the allow and deny list
+func (p *RecipientPolicy) check(phone string) error {
+	if p == nil {
+		return nil
+	}
+
+	for _, pattern := range p.Deny {
+		if matchPattern(pattern, phone) {
+			return ErrRecipientDenied
+		}
+	}
+
+	if len(p.Allow) == 0 {
+		return nil
+	}
+	for _, pattern := range p.Allow {
+		if matchPattern(pattern, phone) {
+			return nil
+		}
+	}
+
+	return ErrRecipientNotAllowed
+}
+
+// matchPattern checks whether the phone number (formatted as E.164) matches the pattern
+func matchPattern(pattern, phone string) bool {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return false
+	}
+
+	// prefix pattern, e.g. `+62812*` or `0812*`
+	if strings.HasSuffix(pattern, "*") {
+		return matchPrefix(strings.TrimSuffix(pattern, "*"), phone)
+	}
+
+	// explicit number
+	if number, err := ph.Normalize(pattern, ph.DefaultRegion); err == nil {
+		return number == phone
+	}
+
+	// otherwise, it is a prefix pattern, e.g. `+62`
+	return matchPrefix(pattern, phone)
+}
+
+// matchPrefix checks whether the phone number (formatted as E.164) starts with the prefix
+// a local prefix is normalized with the default region (e.g. `0812` -> `+62812`), an invalid prefix never matches
+func matchPrefix(prefix, phone string) bool {
+	// a bare `*` matches every number
+	if strings.TrimSpace(prefix) == "" {
+		return true
+	}
+
+	normalized, err := ph.NormalizePrefix(prefix, ph.DefaultRegion)
+	if err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(phone, normalized)
+}
+
+// onWhatsappEntry defines the cached IsOnWhatsApp result
+type onWhatsappEntry struct {
+	JID       types.JID
+	IsIn      bool
+	ExpiredAt time.Time
+}
+
+// onWhatsappCache stores the IsOnWhatsApp results, the oldest one is evicted first
+type onWhatsappCache struct {
+	mu      sync.Mutex
+	size    int
+	order   []string
+	entries map[string]onWhatsappEntry
+}
+
+// newOnWhatsappCache builds a new IsOnWhatsApp cache
+func newOnWhatsappCache(size int) *onWhatsappCache {
+	return &onWhatsappCache{
+		size:    size,
+		entries: make(map[string]onWhatsappEntry),
+	}
+}
+
+// get gets the cached result, if it exists and has not expired yet
+func (c *onWhatsappCache) get(phone string) (onWhatsappEntry, bool) {
+	if c == nil {
+		return onWhatsappEntry{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// the expired entries are kept until they are evicted or replaced
+	entry, ok := c.entries[phone]
+	if !ok || time.Now().After(entry.ExpiredAt) {
+		return onWhatsappEntry{}, false
+	}
+
+	return entry, true
+}
+
+// put stores the result
+func (c *onWhatsappCache) put(phone string, jid types.JID, isIn bool, ttl time.Duration) {
+	if c == nil || ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.entries[phone]; !exists {
+		c.order = append(c.order, phone)
+	}
+	c.entries[phone] = onWhatsappEntry{
+		JID:       jid,
+		IsIn:      isIn,
+		ExpiredAt: time.Now().Add(ttl),
+	}
+
+	// evicts the oldest results
+	for len(c.order) > c.size {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// lookupOnWhatsapp checks if this number available on Whatsapp or not, the result is cached based on the policy
+func (wb *WaBot) lookupOnWhatsapp(phone string) (types.JID, bool, error) {
+	if entry, ok := wb.onWhatsapp.get(phone); ok {
+		return entry.JID, entry.IsIn, nil
+	}
+
+	onWhatsapp, err := wb.Client.IsOnWhatsApp([]string{phone})
+	if err != nil {
+		return types.EmptyJID, false, err
+	}
+	wb.Log.Debug(fmt.Sprintf("%v", onWhatsapp))
+	if len(onWhatsapp) == 0 {
+		return types.EmptyJID, false, nil
+	}
+
+	ttl := time.Duration(0)
+	if wb.Policy != nil {
+		ttl = wb.Policy.OnWhatsappTTL
+	}
+	wb.onWhatsapp.put(phone, onWhatsapp[0].JID, onWhatsapp[0].IsIn, ttl)
+
+	return onWhatsapp[0].JID, onWhatsapp[0].IsIn, nil
+}
+
+// autoAddContact adds the sender of the incoming message to the contact list, if enabled by the policy
+func (wb *WaBot) autoAddContact(sender types.JID, name string) {
+	if wb.Policy == nil || !wb.Policy.AutoAddContacts {
+		return
+	}
+
+	contact, err := wb.Client.Store.Contacts.GetContact(sender.ToNonAD())
+	if err == nil && contact.Found && contact.FullName != "" {
+		return
+	}
+
+	err = wb.Client.Store.Contacts.PutContactName(sender.ToNonAD(), name, name)
+	if err != nil {
+		wb.Log.Warn(fmt.Sprintf("failed to add [%s] to the contact list", sender.User))
+	}
+}
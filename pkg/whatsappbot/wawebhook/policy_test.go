@@ -0,0 +1,67 @@
+package wawebhook
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestMatchPattern(t *testing.T) {
+	phone := "+6281234567890"
+
+	for _, pattern := range []string{"+6281234567890", "081234567890", "+62812*", "0812*", "0812 *", "+62", "*"} {
+		if !matchPattern(pattern, phone) {
+			t.Fatalf("expected [%s] to match", pattern)
+		}
+	}
+	for _, pattern := range []string{"", "0813*", "+60*", "abc*", "081234567899"} {
+		if matchPattern(pattern, phone) {
+			t.Fatalf("expected [%s] not to match", pattern)
+		}
+	}
+}
+
+func TestRecipientPolicyCheck(t *testing.T) {
+	policy := &RecipientPolicy{Allow: []string{"0812*"}, Deny: []string{"081299*"}}
+
+	if err := policy.check("+6281234567890"); err != nil {
+		t.Fatalf("expected the local allow pattern to match, got %v", err)
+	}
+	if err := policy.check("+6281299000000"); !errors.Is(err, ErrRecipientDenied) {
+		t.Fatalf("expected the local deny pattern to match, got %v", err)
+	}
+	if err := policy.check("+6281312345678"); !errors.Is(err, ErrRecipientNotAllowed) {
+		t.Fatalf("expected the recipient not to be allowed, got %v", err)
+	}
+}
+
+func TestOnWhatsappCacheIsBounded(t *testing.T) {
+	c := newOnWhatsappCache(2)
+	for i := 0; i < 5; i++ {
+		c.put(fmt.Sprint(i), types.EmptyJID, true, time.Minute)
+	}
+
+	if len(c.entries) != 2 || len(c.order) != 2 {
+		t.Fatalf("expected 2 cached results, got %d", len(c.entries))
+	}
+	if _, ok := c.get("0"); ok {
+		t.Fatal("expected the oldest result to be evicted")
+	}
+	if _, ok := c.get("4"); !ok {
+		t.Fatal("expected the latest result to be cached")
+	}
+
+	// the expired result is replaced in place, rather than duplicated in the eviction order
+	c.put("4", types.EmptyJID, true, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if _, ok := c.get("4"); ok {
+		t.Fatal("expected the result to be expired")
+	}
+	c.put("4", types.EmptyJID, true, time.Minute)
+	if len(c.order) != 2 {
+		t.Fatalf("expected 2 results in the eviction order, got %d", len(c.order))
+	}
+}
@@ -0,0 +1,119 @@
+package wawebhook
+
+import (
+	"fmt"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"go.uber.org/zap"
+)
+
+// protocolEvent defines the captured reaction, revoke or edit event
+type protocolEvent struct {
+	EventType string
+	Key       *waProto.MessageKey
+	Reaction  string
+	Message   string
+}
+
+// extractText extracts the text content of the message
+// sometimes the text is not in the Conversation, but in the ExtendedTextMessage
+func extractText(msg *waProto.Message) string {
+	if text := msg.GetConversation(); text != "" {
+		return text
+	}
+
+	return msg.GetExtendedTextMessage().GetText()
+}
+
+// extractProtocolEvent extracts reaction, revoke and edit events from the captured message
+// it returns nil if the captured message is a regular message
+func extractProtocolEvent(msg *waProto.Message) *protocolEvent {
+	if reaction := msg.GetReactionMessage(); reaction != nil {
+		// an empty reaction text means that the reaction has been removed
+		return &protocolEvent{
+			EventType: ReactionMessage,
+			Key:       reaction.GetKey(),
+			Reaction:  reaction.GetText(),
+		}
+	}
+
+	protocolMsg := msg.GetProtocolMessage()
+	if protocolMsg == nil {
+		return nil
+	}
+
+	switch protocolMsg.GetType() {
+	case waProto.ProtocolMessage_REVOKE:
+		return &protocolEvent{
+			EventType: RevokedMessage,
+			Key:       protocolMsg.GetKey(),
+		}
+	case waProto.ProtocolMessage_MESSAGE_EDIT:
+		return &protocolEvent{
+			EventType: EditedMessage,
+			Key:       protocolMsg.GetKey(),
+			Message:   extractText(protocolMsg.GetEditedMessage()),
+		}
+	default:
+		// other protocol messages (e.g. history sync) are not forwarded
+		return nil
+	}
+}
+
+// forwardProtocolEvent forwards the captured reaction, revoke or edit event to the designated webhook
+func (wb *WaBot) forwardProtocolEvent(targetJID *types.JID, v *events.Message, pEvt *protocolEvent) {
+	phone := v.Info.Sender.User
+	targetMsgId := pEvt.Key.GetId()
+
+	// only events coming from the other party are forwarded
+	if v.Info.IsFromMe {
+		return
+	}
+
+	wb.Log.Debug(fmt.Sprintf("**** [%s][%s] Received a [%s] event from [%s] (%s) on message [%s]",
+		v.Info.Timestamp, v.Info.ID, pEvt.EventType, v.Info.PushName, phone, targetMsgId))
+
+	// echo mode has nothing to echo for these events
+	if wb.EchoMsg {
+		return
+	}
+
+	resp, err := wb.postToWebhook(&WebhookBody{
+		PhoneOwner:   wb.Phone,
+		EventType:    pEvt.EventType,
+		MsgId:        v.Info.ID,
+		MsgType:      v.Info.Type,
+		Phone:        phone,
+		Name:         v.Info.PushName,
+		Message:      pEvt.Message,
+		TargetJID:    targetJID.String(),
+		TargetDevice: targetJID.User,
+		Timestamp:    v.Info.Timestamp.Format("2006-01-02 15:04:05"),
+		TargetMsgId:  targetMsgId,
+		Reaction:     pEvt.Reaction,
+	})
+	if err != nil {
+		wb.Log.Error(fmt.Sprintf("failed to forward [%s] event to webhook", pEvt.EventType), zap.Error(err))
+		return
+	}
+
+	// the key is relative to the other party: `FromMe` means the target message was sent by the customer
+	targetSender := v.Info.Sender
+	if !pEvt.Key.GetFromMe() && wb.Client.Store.ID != nil {
+		targetSender = *wb.Client.Store.ID
+	}
+
+	// the webhook may reply to the event as well (e.g. reacts back to the target message)
+	target := &replyTarget{
+		Chat:   v.Info.Chat,
+		Sender: targetSender,
+		MsgId:  targetMsgId,
+		Phone:  phone,
+	}
+	if cached, ok := wb.recentMsgs.get(targetMsgId); ok {
+		target.Message = cached.Message
+	}
+	_ = wb.replyMessage(targetJID, target, resp)
+}
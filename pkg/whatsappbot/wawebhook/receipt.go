@@ -0,0 +1,91 @@
+package wawebhook
+
+import (
+	"fmt"
+
+	"go.mau.fi/whatsmeow/types/events"
+	"go.uber.org/zap"
+
+	m "github.com/ardihikaru/go-modules/pkg/messenger"
+	"github.com/ardihikaru/go-modules/pkg/utils/web"
+)
+
+// receiptOf maps the whatsmeow receipt type to the delivery receipt, other receipt types are not forwarded
+func receiptOf(receiptType events.ReceiptType) (string, bool) {
+	switch receiptType {
+	case events.ReceiptTypeDelivered:
+		return m.ReceiptDelivered, true
+	case events.ReceiptTypeRead:
+		return m.ReceiptRead, true
+	case events.ReceiptTypePlayed:
+		return m.ReceiptPlayed, true
+	default:
+		return "", false
+	}
+}
+
+// handleReceipt forwards the delivery receipt of the sent messages
+// to the status callback and to the receipt handler, if any
+func (wb *WaBot) handleReceipt(v *events.Receipt) {
+	if wb.StatusCallbackUrl == "" && wb.ReceiptHandler == nil {
+		return
+	}
+
+	// only receipts of the messages sent to a contact are forwarded
+	if v.IsGroup || v.IsFromMe {
+		return
+	}
+
+	receipt, ok := receiptOf(v.Type)
+	if !ok {
+		return
+	}
+
+	r := &m.DeliveryReceipt{
+		PhoneOwner: wb.Phone,
+		MsgIds:     v.MessageIDs,
+		Phone:      v.Chat.User,
+		Receipt:    receipt,
+		Timestamp:  v.Timestamp,
+	}
+
+	wb.Log.Debug(fmt.Sprintf("**** [%s] Received a [%s] receipt from [%s] on message(s) %v",
+		v.Timestamp, receipt, r.Phone, v.MessageIDs))
+
+	if wb.ReceiptHandler != nil {
+		wb.ReceiptHandler(r)
+	}
+
+	if wb.StatusCallbackUrl != "" {
+		err := wb.postReceipt(r)
+		if err != nil {
+			wb.Log.Error("failed to forward the delivery receipt to the status callback", zap.Error(err))
+		}
+	}
+}
+
+// postReceipt posts the delivery receipt to the status callback
+func (wb *WaBot) postReceipt(r *m.DeliveryReceipt) error {
+	body, err := web.BuildFormBody(r)
+	if err != nil {
+		return err
+	}
+
+	req, err := web.BuildRequest(wb.StatusCallbackUrl, web.HttpPost, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(web.HeaderContentTypeKey, web.HeaderContentTypeValue)
+
+	resp, err := wb.HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("got unexpected status code [%d] from the status callback", resp.StatusCode)
+	}
+
+	return nil
+}
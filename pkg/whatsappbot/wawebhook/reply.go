@@ -4,21 +4,57 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 
+	waProto "go.mau.fi/whatsmeow/binary/proto"
 	"go.mau.fi/whatsmeow/types"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/ardihikaru/go-modules/pkg/utils/httputils"
+	ph "github.com/ardihikaru/go-modules/pkg/utils/phone"
 )
 
 type ReplyMessage struct {
 	Message       string
 	WithImage     bool
 	ImageFileName string
+	Reaction      string `json:"reaction,omitempty"`
+	RevokeMsgId   string `json:"revoke_msg_id,omitempty"`
+	Quote         bool   `json:"quote,omitempty"`
+}
+
+// replyTarget defines the captured message to reply to
+type replyTarget struct {
+	Chat   types.JID
+	Sender types.JID
+	MsgId  string
+	Phone  string
+
+	// Message is the captured message content, used to quote it on reply
+	Message *waProto.Message
+}
+
+// hasContent checks whether the reply contains any message to send
+func (r *ReplyMessage) hasContent() bool {
+	return r.Message != "" || r.WithImage
+}
+
+// contextInfo builds the context info to quote the captured message
+func (t *replyTarget) contextInfo() *waProto.ContextInfo {
+	ctxInfo := &waProto.ContextInfo{
+		StanzaId:      proto.String(t.MsgId),
+		QuotedMessage: t.Message,
+	}
+	if !t.Sender.IsEmpty() {
+		ctxInfo.Participant = proto.String(t.Sender.ToNonAD().String())
+	}
+
+	return ctxInfo
 }
 
 // replyMessage replies the captured message and do reply
-func (wb *WaBot) replyMessage(targetJID *types.JID, phone string, resp *httputils.Response) error {
+func (wb *WaBot) replyMessage(targetJID *types.JID, target *replyTarget, resp *httputils.Response) error {
 	// extracts response payload
 	byteData, _ := json.Marshal(resp.Data)
 	replyMsgObj := ReplyMessage{}
@@ -29,23 +65,45 @@ func (wb *WaBot) replyMessage(targetJID *types.JID, phone string, resp *httputil
 	}
 
 	if targetJID.User == "" {
-		// enriches with `+` symbol if missing
-		if phone[0:1] != "+" {
-			phone = fmt.Sprintf("+%s", phone)
-		}
-
 		// validates phone number and get the recipient
-		recipient, err := wb.ValidateAndGetRecipient(phone, true)
+		// the phone is the JID user of the sender, which may not be in the default region
+		recipient, err := wb.ValidateAndGetRecipient(ph.FromJIDUser(target.Phone), true)
 		if err != nil {
-			wb.Log.Error(fmt.Sprintf("phone [%s] got validation error(s)", phone), zap.Error(err))
+			wb.Log.Error(fmt.Sprintf("phone [%s] got validation error(s)", target.Phone), zap.Error(err))
 			return err
 		}
 
+		// reacts to the captured message
+		if replyMsgObj.Reaction != "" {
+			err = wb.SendReaction(target.Chat, target.Sender, target.MsgId, replyMsgObj.Reaction)
+			if err != nil {
+				wb.Log.Error("failed to react to the captured message", zap.Error(err))
+				return err
+			}
+		}
+
+		// revokes a previously sent message
+		if replyMsgObj.RevokeMsgId != "" {
+			err = wb.RevokeMessage(*recipient, replyMsgObj.RevokeMsgId)
+			if err != nil {
+				wb.Log.Error("failed to revoke the designated message", zap.Error(err))
+				return err
+			}
+		}
+
+		// quotes the captured message, if requested
+		var ctxInfo *waProto.ContextInfo
+		if replyMsgObj.Quote {
+			ctxInfo = target.contextInfo()
+		}
+
 		// sends a reply message
-		err = wb.sendMsgAndWait(*recipient, replyMsgObj)
-		if err != nil {
-			wb.Log.Error("failed to reply the captured message", zap.Error(err))
-			return err
+		if replyMsgObj.hasContent() {
+			_, err = wb.sendMsgAndWait(*recipient, replyMsgObj, ctxInfo)
+			if err != nil {
+				wb.Log.Error("failed to reply the captured message", zap.Error(err))
+				return err
+			}
 		}
 
 	} else {
@@ -55,66 +113,92 @@ func (wb *WaBot) replyMessage(targetJID *types.JID, phone string, resp *httputil
 	return nil
 }
 
-// ValidateAndGetRecipient validates the phone number
-func (wb *WaBot) ValidateAndGetRecipient(phone string, ignoreInContactList bool) (*types.JID, error) {
-	var err error
-	phones := make([]string, 1)
-
-	// enriches with `+` symbol if missing
-	if phone[0:1] != "+" {
-		phone = fmt.Sprintf("+%s", phone)
+// ValidateAndGetRecipient validates the phone number against the recipient policy of this session
+// the returned error is a *RecipientError, use errors.Is to check the reason (e.g. ErrNotOnWhatsapp)
+func (wb *WaBot) ValidateAndGetRecipient(rawPhone string, ignoreInContactList bool) (*types.JID, error) {
+	// formats as E.164 (with `+` symbol)
+	phone, err := ph.Normalize(rawPhone, ph.DefaultRegion)
+	if err != nil {
+		wb.Log.Warn(fmt.Sprintf("this number [%s] is not a valid phone number", rawPhone))
+		return nil, &RecipientError{Phone: rawPhone, Err: fmt.Errorf("%w: %s", ErrInvalidPhone, err.Error())}
 	}
 
-	phones[0] = phone
+	// checks the allow and deny list
+	err = wb.Policy.check(phone)
+	if err != nil {
+		wb.Log.Warn(fmt.Sprintf("this number [%s] is rejected by the recipient policy", phone))
+		return nil, &RecipientError{Phone: phone, Err: err}
+	}
 
 	// checks if this number available on Whatsapp or not
-	onWhatsapp, err := wb.Client.IsOnWhatsApp(phones)
+	recipient, isIn, err := wb.lookupOnWhatsapp(phone)
 	if err != nil {
-		return nil, err
+		return nil, &RecipientError{Phone: phone, Err: err}
 	}
-	wb.Log.Debug(fmt.Sprintf("%v", onWhatsapp))
 
 	// extracts non-AD JID
-	recipient := onWhatsapp[0].JID
-	if !onWhatsapp[0].IsIn {
+	if !isIn {
 		wb.Log.Warn(fmt.Sprintf("this number [%s] is not available in Whatsapp", phone))
-		return nil, fmt.Errorf("this number is not available in Whatsapp")
+		return nil, &RecipientError{Phone: phone, Err: ErrNotOnWhatsapp}
 	}
 
 	// check if in contact list
-	// TODO: if not in the contact list, what to do?
+	// the contact list is required if the caller or the recipient policy asks for it
+	contactsOnly := !ignoreInContactList || (wb.Policy != nil && wb.Policy.ContactsOnly)
 	contact, err := wb.Client.Store.Contacts.GetContact(recipient)
-	if err != nil && !ignoreInContactList {
+	if err != nil {
+		if contactsOnly {
+			return nil, &RecipientError{Phone: phone, Err: err}
+		}
+		wb.Log.Warn(fmt.Sprintf("failed to get contact of this number [%s]", phone))
+	}
+	if contactsOnly && !inContactList(contact) {
 		wb.Log.Warn(fmt.Sprintf("this number [%s] is not on your contact list!", phone))
-		return nil, err
+		return nil, &RecipientError{Phone: phone, Err: ErrNotInContacts}
 	}
 	wb.Log.Debug(fmt.Sprintf("%v", contact))
 
 	return &recipient, nil
 }
 
-// sendMsgAndWait sends the message to the designated device
-func (wb *WaBot) sendMsgAndWait(recipient types.JID, msgObj ReplyMessage) error {
-	var err error
+// inContactList checks whether the contact has been saved on the contact list
+// a contact with a push name only is not considered as a saved contact
+func inContactList(contact types.ContactInfo) bool {
+	return contact.Found && (contact.FullName != "" || contact.FirstName != "")
+}
 
+// sendMsgAndWait sends the message to the designated device, and returns the ID of the sent message
+// the message quotes another message if the context info is provided
+func (wb *WaBot) sendMsgAndWait(recipient types.JID, msgObj ReplyMessage,
+	ctxInfo *waProto.ContextInfo) (string, error) {
 	if msgObj.WithImage {
 		imgPath := fmt.Sprintf("%s/%s", wb.ImageDir, msgObj.ImageFileName)
 		imgInBytes, uploaded, err := wb.UploadImgToWhatsapp(imgPath)
+		if err != nil {
+			return "", err
+		}
 
 		// prepares image information
 		contentType := http.DetectContentType(*imgInBytes)
 		fileLength := uint64(len(*imgInBytes))
 
-		if err != nil {
-			return err
-		}
-		err = wb.SendImgMsg(recipient, uploaded, msgObj.Message, contentType, fileLength)
-	} else {
-		err = wb.SendMsg(recipient, msgObj.Message)
+		return wb.sendImgMsg(recipient, uploaded, msgObj.Message, contentType, fileLength, ctxInfo)
 	}
-	if err != nil {
-		return err
+
+	return wb.sendTextMsg(recipient, msgObj.Message, ctxInfo)
+}
+
+// autoReply sends the out-of-hours auto-reply, if enabled and not sent yet in the current closed window
+func (wb *WaBot) autoReply(chat types.JID, name string, ts time.Time) {
+	if wb.AutoReply == nil {
+		return
 	}
 
-	return nil
+	_, err := wb.AutoReply.Reply(chat.String(), name, ts, func(reply string) error {
+		_, err := wb.sendTextMsg(chat, reply, nil)
+		return err
+	})
+	if err != nil {
+		wb.Log.Warn(fmt.Sprintf("failed to send the auto-reply to [%s] -> %s", chat.User, err.Error()))
+	}
 }
@@ -0,0 +1,89 @@
+package wawebhook
+
+import (
+	"context"
+
+	m "github.com/ardihikaru/go-modules/pkg/messenger"
+)
+
+// LocalSender sends the messages of the telegram bridge in-process, without the HTTP hop of messenger.Messenger
+// it is used when the whatsapp bot runs in the same binary as the telegram bot
+type LocalSender struct {
+	Bot *WaBot
+}
+
+// NewLocalSender builds the in-process sender of the designated whatsapp bot
+func NewLocalSender(bot *WaBot) *LocalSender {
+	return &LocalSender{Bot: bot}
+}
+
+// SendToWhatsapp sends the text or media message to the designated phone, and returns the ID of the sent message
+func (s *LocalSender) SendToWhatsapp(ctx context.Context, msg m.PostWhatsappMsg) (*m.SendResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	recipient, err := s.Bot.ValidateAndGetRecipient(msg.Phone, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var msgId string
+	if msg.Media != nil {
+		msgId, err = s.Bot.sendMediaMsg(*recipient, &MediaPayload{
+			Type:     msg.Media.Type,
+			FileName: msg.Media.FileName,
+			MimeType: msg.Media.MimeType,
+			Data:     msg.Media.Data,
+		}, msg.Message, nil)
+	} else {
+		msgId, err = s.Bot.sendTextMsg(*recipient, msg.Message, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &m.SendResult{MsgId: msgId, Message: m.SentConfirmation}, nil
+}
+
+// GetStatus gets the status of the whatsapp session
+func (s *LocalSender) GetStatus(ctx context.Context) (*m.GatewayStatus, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	status := s.Bot.Status()
+
+	return &m.GatewayStatus{
+		Phone:     status.Phone,
+		Connected: status.Connected,
+		LoggedIn:  status.LoggedIn,
+	}, nil
+}
+
+// GetContact gets the contact information of the designated phone
+func (s *LocalSender) GetContact(ctx context.Context, phone string) (*m.ContactInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	contact, err := s.Bot.GetContactInfo(phone)
+	if err != nil {
+		return nil, err
+	}
+
+	return &m.ContactInfo{
+		Phone:        contact.Phone,
+		JID:          contact.JID,
+		OnWhatsapp:   contact.OnWhatsapp,
+		InContacts:   contact.InContacts,
+		FullName:     contact.FullName,
+		FirstName:    contact.FirstName,
+		PushName:     contact.PushName,
+		BusinessName: contact.BusinessName,
+		About:        contact.About,
+	}, nil
+}
+
+// ensures that LocalSender implements messenger.Sender
+var _ m.Sender = (*LocalSender)(nil)
@@ -17,13 +17,19 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/ardihikaru/go-modules/pkg/logger"
+	m "github.com/ardihikaru/go-modules/pkg/messenger"
+	"github.com/ardihikaru/go-modules/pkg/schedule"
 	fh "github.com/ardihikaru/go-modules/pkg/utils/filehandler"
+	ph "github.com/ardihikaru/go-modules/pkg/utils/phone"
 	qrCodeH "github.com/ardihikaru/go-modules/pkg/utils/qrcodehandler"
 )
 
 const (
 	IncomingMessage = "INCOMING_MESSAGE"
 	OutgoingMessage = "OUTGOING_MESSAGE"
+	ReactionMessage = "REACTION_MESSAGE"
+	RevokedMessage  = "REVOKED_MESSAGE"
+	EditedMessage   = "EDITED_MESSAGE"
 )
 
 // WaManager defines the whatsapp container
@@ -43,6 +49,26 @@ type WaBot struct {
 	ImageDir       string
 	EchoMsg        bool
 	WHookEnabled   bool
+
+	// StatusCallbackUrl receives the delivery receipts (see messenger.DeliveryReceipt) of the sent messages if set
+	StatusCallbackUrl string
+
+	// ReceiptHandler handles the delivery receipts in-process if exists, e.g. when the telegram bridge runs
+	// in the same binary
+	ReceiptHandler func(r *m.DeliveryReceipt)
+
+	// AutoReply replies the messages received out of the business hours if exists,
+	// the messages are still forwarded to the webhook
+	AutoReply *schedule.AutoReplier
+
+	// Policy defines the recipient policy of this session, nil allows every recipient available on Whatsapp
+	Policy *RecipientPolicy
+
+	// onWhatsapp caches the IsOnWhatsApp results, based on the TTL of the recipient policy
+	onWhatsapp *onWhatsappCache
+
+	// recentMsgs stores the recently received messages, e.g. to quote them on reply
+	recentMsgs *messageCache
 }
 
 // BotClientList defines the variable to store WaBot objects
@@ -95,9 +121,10 @@ func NewWhatsappClient(httpClient *http.Client, webhookUrl, imageDir string, con
 	client := whatsmeow.NewClient(myDevice, clientLog)
 
 	// generates file path to store the qr code
-	// makes sure that phone contains + symbol
-	if phone[0:1] != "+" {
-		phone = fmt.Sprintf("+%s", phone)
+	// makes sure that phone is formatted as E.164 (contains + symbol)
+	phone, err = ph.Normalize(phone, ph.DefaultRegion)
+	if err != nil {
+		return nil, err
 	}
 	filePath := fmt.Sprintf("%s/%s.png", fileDir, phone)
 
@@ -147,6 +174,8 @@ func buildWhatsappBot(client *whatsmeow.Client, log *logger.Logger, httpClient *
 		ImageDir:     imageDir,
 		EchoMsg:      echoMsg,
 		WHookEnabled: wHookEnabled,
+		onWhatsapp:   newOnWhatsappCache(defaultOnWhatsappCacheSize),
+		recentMsgs:   newMessageCache(defaultMsgCacheSize),
 	}
 }
 
@@ -194,16 +223,33 @@ func (wb *WaBot) eventHandler(evt interface{}) {
 			return
 		}
 
+		// reactions, revokes and edits are forwarded as distinct events
+		if pEvt := extractProtocolEvent(v.Message); pEvt != nil {
+			wb.forwardProtocolEvent(&deviceTargetJID, v, pEvt)
+			return
+		}
+
 		// monkey patch! sometimes the text is not in the Conversation, but in the ExtendedTextMessage
 		// e.g. from Albert / Taiwan
-		if message == "" && v.Message.ExtendedTextMessage != nil {
-			message = *v.Message.ExtendedTextMessage.Text
+		if message == "" {
+			message = extractText(v.Message)
+		}
+
+		// out of the business hours, the customer is told when to expect the answer, even on media messages
+		if !v.Info.IsFromMe {
+			wb.autoReply(chatJID, name, ts)
 		}
 
 		if message != "" && v.Info.DeviceSentMeta == nil {
 			wb.Log.Debug(fmt.Sprintf("**** [%s][%s] Received a [%s] message from [%s] (%s) -> '%s'",
 				ts, msgId, msgType, name, phone, message))
 
+			// adds the sender to the contact list, if enabled by the recipient policy
+			wb.autoAddContact(senderJID, name)
+
+			// keeps the message, so that it can be quoted later on
+			wb.recentMsgs.put(msgId, cachedMessage{Chat: chatJID, Sender: senderJID, Message: v.Message})
+
 			// on receiving message, send the message to the designated webhook
 			resp, err := wb.sendToWebhook(&deviceTargetJID, IncomingMessage, msgId, msgType, phone, name, message,
 				wb.Phone, ts)
@@ -211,7 +257,13 @@ func (wb *WaBot) eventHandler(evt interface{}) {
 				wb.Log.Error("failed to forward incoming message to webhook", zap.Error(err))
 			} else {
 				// sends the reply message
-				err = wb.replyMessage(&deviceTargetJID, phone, resp)
+				err = wb.replyMessage(&deviceTargetJID, &replyTarget{
+					Chat:    chatJID,
+					Sender:  senderJID,
+					MsgId:   msgId,
+					Phone:   phone,
+					Message: v.Message,
+				}, resp)
 
 				// on success, mark as read
 				if err == nil {
@@ -232,6 +284,8 @@ func (wb *WaBot) eventHandler(evt interface{}) {
 			//	wb.Log.Error("failed to forward outgoing message to webhook", zap.Error(err))
 			//}
 		}
+	case *events.Receipt:
+		wb.handleReceipt(v)
 	}
 }
 
@@ -28,6 +28,8 @@ type WebhookBody struct {
 	TargetJID    string `json:"target_jid"`
 	TargetDevice string `json:"target_device"`
 	Timestamp    string `json:"timestamp"`
+	TargetMsgId  string `json:"target_msg_id,omitempty"`
+	Reaction     string `json:"reaction,omitempty"`
 }
 
 // sendToWebhook sends the captured message to webhook
@@ -56,65 +58,104 @@ func (wb *WaBot) sendToWebhook(targetJID *types.JID, evtType, msgId, msgType, ph
 			Timestamp:    ts.Format("2006-01-02 15:04:05"),
 		}
 
-		// builds body
-		body, err := web.BuildFormBody(bodyObj)
+		return wb.postToWebhook(bodyObj)
+	}
+}
 
-		// builds request
-		req, err := web.BuildRequest(wb.WebhookUrl, "POST", body)
-		if err != nil {
-			return nil, err
-		}
+// postToWebhook sends the prepared body to the designated webhook
+func (wb *WaBot) postToWebhook(bodyObj *WebhookBody) (*httputils.Response, error) {
+	// builds body
+	body, err := web.BuildFormBody(bodyObj)
+	if err != nil {
+		return nil, err
+	}
 
-		// enriches with pre-generated headers
-		req.Header.Set("Content-Type", "application/json") // default header
+	// builds request
+	req, err := web.BuildRequest(wb.WebhookUrl, "POST", body)
+	if err != nil {
+		return nil, err
+	}
 
-		// sends request
-		resp, err := wb.HttpClient.Do(req)
-		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
+	// enriches with pre-generated headers
+	req.Header.Set("Content-Type", "application/json") // default header
 
-		// validates response
-		if resp.StatusCode != 200 {
-			return nil, fmt.Errorf("got error response from the webhook")
-		}
+	// sends request
+	resp, err := wb.HttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 
-		// read response body
-		bodyBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
+	// validates response
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("got error response from the webhook")
+	}
 
-		// converts response body to clinicRespPayload struct
-		var respPayload httputils.Response
-		err = json.Unmarshal(bodyBytes, &respPayload)
-		if err != nil {
-			return nil, err
-		}
+	// read response body
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
 
-		return &respPayload, nil
+	// converts response body to clinicRespPayload struct
+	var respPayload httputils.Response
+	err = json.Unmarshal(bodyBytes, &respPayload)
+	if err != nil {
+		return nil, err
 	}
+
+	return &respPayload, nil
 }
 
 // SendMsg sends message to designated whatsapp number
 func (wb *WaBot) SendMsg(recipient types.JID, msg string) error {
-	resp, err := wb.Client.SendMessage(context.Background(), recipient, &waProto.Message{
+	_, err := wb.sendTextMsg(recipient, msg, nil)
+	return err
+}
+
+// SendQuotedMsg sends message to designated whatsapp number, quoting the designated message
+// the quoted message content is taken from the recently captured messages, if exists
+func (wb *WaBot) SendQuotedMsg(recipient types.JID, msg, quotedMsgId string) error {
+	_, err := wb.sendTextMsg(recipient, msg, wb.quoteContextInfo(recipient, quotedMsgId))
+	return err
+}
+
+// sendTextMsg sends text message to designated whatsapp number, and returns the ID of the sent message
+// a text message with context info (e.g. a quoted message) must be sent as an extended text message
+func (wb *WaBot) sendTextMsg(recipient types.JID, msg string, ctxInfo *waProto.ContextInfo) (string, error) {
+	waMsg := &waProto.Message{
 		Conversation: proto.String(msg),
-	})
+	}
+	if ctxInfo != nil {
+		waMsg = &waProto.Message{
+			ExtendedTextMessage: &waProto.ExtendedTextMessage{
+				Text:        proto.String(msg),
+				ContextInfo: ctxInfo,
+			},
+		}
+	}
+
+	resp, err := wb.Client.SendMessage(context.Background(), recipient, waMsg)
 	if err != nil {
 		wb.Log.Debug(fmt.Sprintf("[to:%s] failed to send message: %s", recipient.User, msg))
-		return err
+		return "", err
 	} else {
 		wb.Log.Debug(fmt.Sprintf("[to:%s] message sent (server timestamp: %s)", recipient.User, resp.Timestamp))
 	}
 
-	return nil
+	return resp.ID, nil
 }
 
 // SendImgMsg sends image-based message to designated whatsapp number
 func (wb *WaBot) SendImgMsg(recipient types.JID, uploadedImg *whatsmeow.UploadResponse, imgCaption, contentType string,
 	fileLength uint64) error {
+	_, err := wb.sendImgMsg(recipient, uploadedImg, imgCaption, contentType, fileLength, nil)
+	return err
+}
+
+// sendImgMsg sends image-based message to designated whatsapp number, and returns the ID of the sent message
+func (wb *WaBot) sendImgMsg(recipient types.JID, uploadedImg *whatsmeow.UploadResponse, imgCaption, contentType string,
+	fileLength uint64, ctxInfo *waProto.ContextInfo) (string, error) {
 	msg := &waProto.Message{ImageMessage: &waProto.ImageMessage{
 		Caption:       proto.String(imgCaption),
 		Url:           proto.String(uploadedImg.URL),
@@ -124,17 +165,36 @@ func (wb *WaBot) SendImgMsg(recipient types.JID, uploadedImg *whatsmeow.UploadRe
 		FileEncSha256: uploadedImg.FileEncSHA256,
 		FileSha256:    uploadedImg.FileSHA256,
 		FileLength:    proto.Uint64(fileLength),
+		ContextInfo:   ctxInfo,
 	}}
 
 	resp, err := wb.Client.SendMessage(context.Background(), recipient, msg)
 	if err != nil {
 		wb.Log.Debug(fmt.Sprintf("[to:%s] failed to send image message: %s", recipient.User, msg))
-		return err
+		return "", err
 	} else {
 		wb.Log.Debug(fmt.Sprintf("[to:%s] message sent (server timestamp: %s)", recipient.User, resp.Timestamp))
 	}
 
-	return nil
+	return resp.ID, nil
+}
+
+// quoteContextInfo builds the context info to quote the designated message in a private chat
+// if the message is no longer cached, the recipient is assumed as the sender of the quoted message
+func (wb *WaBot) quoteContextInfo(recipient types.JID, quotedMsgId string) *waProto.ContextInfo {
+	target := &replyTarget{
+		Chat:   recipient,
+		Sender: recipient,
+		MsgId:  quotedMsgId,
+	}
+
+	if cached, ok := wb.recentMsgs.get(quotedMsgId); ok {
+		target.Chat = cached.Chat
+		target.Sender = cached.Sender
+		target.Message = cached.Message
+	}
+
+	return target.contextInfo()
 }
 
 // UploadImgToWhatsapp uploads the prepared image to Whatsapp server
@@ -148,9 +208,60 @@ func (wb *WaBot) UploadImgToWhatsapp(imgPath string) (*[]byte, *whatsmeow.Upload
 	// then, uploads to whatsapp server
 	uploaded, err := wb.Client.Upload(context.Background(), imgInBytes, whatsmeow.MediaImage)
 	if err != nil {
-		wb.Log.Debug(fmt.Sprintf("[to:%s] failed to upload file"))
+		wb.Log.Debug(fmt.Sprintf("[path:%s] failed to upload file", imgPath))
 		return nil, nil, err
 	}
 
 	return &imgInBytes, &uploaded, nil
 }
+
+// SendReaction reacts to the designated message with an emoji (e.g. emoji.CheckMark)
+// an empty reaction removes the previously sent reaction
+func (wb *WaBot) SendReaction(chat, sender types.JID, msgId, reaction string) error {
+	key := &waProto.MessageKey{
+		RemoteJid: proto.String(chat.String()),
+		FromMe:    proto.Bool(wb.isOwnJID(sender)),
+		Id:        proto.String(msgId),
+	}
+	if chat.Server != types.DefaultUserServer && !sender.IsEmpty() {
+		key.Participant = proto.String(sender.ToNonAD().String())
+	}
+
+	resp, err := wb.Client.SendMessage(context.Background(), chat, &waProto.Message{
+		ReactionMessage: &waProto.ReactionMessage{
+			Key:               key,
+			Text:              proto.String(reaction),
+			SenderTimestampMs: proto.Int64(time.Now().UnixMilli()),
+		},
+	})
+	if err != nil {
+		wb.Log.Debug(fmt.Sprintf("[to:%s] failed to react to message [%s]", chat.User, msgId))
+		return err
+	} else {
+		wb.Log.Debug(fmt.Sprintf("[to:%s] reaction sent (server timestamp: %s)", chat.User, resp.Timestamp))
+	}
+
+	return nil
+}
+
+// RevokeMessage deletes the designated message (sent by this device) for everyone in the chat
+func (wb *WaBot) RevokeMessage(chat types.JID, msgId string) error {
+	resp, err := wb.Client.SendMessage(context.Background(), chat, wb.Client.BuildRevoke(chat, types.EmptyJID, msgId))
+	if err != nil {
+		wb.Log.Debug(fmt.Sprintf("[to:%s] failed to revoke message [%s]", chat.User, msgId))
+		return err
+	} else {
+		wb.Log.Debug(fmt.Sprintf("[to:%s] message revoked (server timestamp: %s)", chat.User, resp.Timestamp))
+	}
+
+	return nil
+}
+
+// isOwnJID checks whether the JID belongs to this device
+func (wb *WaBot) isOwnJID(jid types.JID) bool {
+	if jid.IsEmpty() || wb.Client.Store.ID == nil {
+		return false
+	}
+
+	return jid.User == wb.Client.Store.ID.User
+}